@@ -0,0 +1,142 @@
+// Command slkiosk serves a single auto-refreshing HTML departure board for one stop,
+// meant for a wall-mounted tablet. It reuses the bot's SL client, including its
+// departures cache, so any number of screens cost one upstream call per TTL.
+// SL call counters are served at /metrics in the Prometheus text format.
+//
+//	go run ./cmd/slkiosk -site 3484 -addr :8080
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/logging"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// board is the page template. The meta refresh keeps the page live without JavaScript,
+// which old tablet browsers handle best.
+var board = template.Must(template.New("board").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { background: #111; color: #eee; font-family: sans-serif; margin: 2em; }
+h1 { font-weight: normal; }
+table { width: 100%; border-collapse: collapse; font-size: 2em; }
+td { padding: 0.3em 0.5em; border-bottom: 1px solid #333; }
+td.line { font-weight: bold; width: 3em; }
+td.mins { text-align: right; width: 5em; }
+.error { color: #f66; }
+.updated { color: #888; margin-top: 1em; }
+</style>
+</head>
+<body>
+<h1>🚌 {{.Title}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<table>
+{{range .Rows}}<tr><td class="line">{{.Line}}</td><td>{{.Text}}</td><td class="mins">{{.Minutes}}</td></tr>
+{{end}}</table>
+<p class="updated">Updated {{.Updated}}</p>
+</body>
+</html>
+`))
+
+type row struct {
+	Line    string
+	Text    string
+	Minutes string
+}
+
+type page struct {
+	Title   string
+	Refresh int
+	Rows    []row
+	Error   string
+	Updated string
+}
+
+func main() {
+	logging.Setup(os.Stderr)
+
+	siteID := flag.String("site", os.Getenv("KIOSK_SITE_ID"), "SL site ID to show (or KIOSK_SITE_ID)")
+	title := flag.String("title", "", "board heading (defaults to the stop name)")
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	count := flag.Int("count", 8, "departures to show")
+	refresh := flag.Duration("refresh", 30*time.Second, "page refresh interval")
+	dryRun := flag.Bool("dry-run", os.Getenv("SL_DRY_RUN") == "1", "use fixtures instead of the SL API")
+	flag.Parse()
+
+	if *siteID == "" {
+		log.Fatal("slkiosk: -site is required")
+	}
+
+	cfg := sl.TransportConfigFromEnv()
+	client := sl.NewClient(sl.NewHTTPClient(cfg), false)
+	if *dryRun {
+		client = sl.NewDryRunClient(cfg)
+	}
+	client.SetFaults(sl.FaultsFromEnv())
+	// Every screen refresh would otherwise be an upstream call.
+	client.SetDeparturesCacheTTL(*refresh, 2*time.Minute)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		p := page{
+			Title:   *title,
+			Refresh: int(refresh.Seconds()),
+			Updated: now.Format("15:04:05"),
+		}
+
+		departures, err := client.GetDepartures(ctx, *siteID)
+		if err != nil {
+			log.Printf("slkiosk: error fetching departures: %v", err)
+			p.Error = "Could not fetch departures. Retrying…"
+		}
+
+		for i, dep := range departures {
+			if i >= *count {
+				break
+			}
+			if p.Title == "" {
+				p.Title = dep.StopArea.Name
+			}
+			mins := "now"
+			if until := dep.Expected.Sub(now); until >= time.Minute {
+				mins = fmt.Sprintf("%d min", int(until.Round(time.Minute).Minutes()))
+			}
+			p.Rows = append(p.Rows, row{Line: dep.Line, Text: sl.FormatDeparture(dep, format.DefaultThresholds), Minutes: mins})
+		}
+		if p.Title == "" {
+			p.Title = "Departures"
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := board.Execute(w, p); err != nil {
+			log.Printf("slkiosk: render: %v", err)
+		}
+	})
+
+	http.Handle("/metrics", client.MetricsHandler())
+
+	log.Printf("slkiosk: serving site %s on http://%s", *siteID, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,201 @@
+// Command slload drives the bot handler with synthetic users to measure throughput,
+// latency and lock contention. It runs fully offline: the SL client is in dry-run
+// mode and Telegram is replaced by an in-process fake (internal/telegramfake).
+//
+//	go run ./cmd/slload -users 50 -rate 500 -duration 10s
+//
+// SL_CHAOS_* variables (see sl.FaultsFromEnv) inject SL failures and latency:
+//
+//	SL_CHAOS_ERROR_RATE=0.1 SL_CHAOS_LATENCY=500ms go run ./cmd/slload
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/bot"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/telegramfake"
+)
+
+// commands is the synthetic workload; each update picks one at random.
+var commands = []string{
+	"to work",
+	"to home",
+	"to work",
+	"to home",
+	"/prefs",
+	"/sethome storgatan",
+	"/setwork frösunda",
+	"from solna centrum to work",
+	"/help",
+}
+
+func main() {
+	users := flag.Int("users", 20, "number of synthetic users")
+	rate := flag.Float64("rate", 100, "total updates per second across all users")
+	duration := flag.Duration("duration", 10*time.Second, "how long to generate load")
+	storeFile := flag.String("store", "", "prefs file (default: a temp file)")
+	mutexProfile := flag.String("mutexprofile", "", "write a pprof mutex profile to this file")
+	verbose := flag.Bool("v", false, "keep handler logging")
+	flag.Parse()
+
+	if *users < 1 || *rate <= 0 {
+		log.Fatal("slload: -users and -rate must be positive")
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	runtime.SetMutexProfileFraction(1)
+
+	fake := telegramfake.NewServer()
+	defer fake.Close()
+
+	api, err := fake.API()
+	if err != nil {
+		fatalf("create bot api: %v", err)
+	}
+
+	path := *storeFile
+	if path == "" {
+		dir, err := os.MkdirTemp("", "slload")
+		if err != nil {
+			fatalf("temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "prefs.json")
+	}
+
+	slClient := sl.NewClient(http.DefaultClient, true)
+	slClient.SetFaults(sl.FaultsFromEnv())
+	handler := bot.NewHandler(slClient, "3484", "3455", store.NewUserStore(path))
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		sent      atomic.Int64
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	// Each user sends at rate/users per second, with a random phase so they don't align.
+	interval := time.Duration(float64(*users) / *rate * float64(time.Second))
+	waitBefore := mutexWaitSeconds()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for u := 0; u < *users; u++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(userID))
+			time.Sleep(time.Duration(rng.Int63n(int64(interval) + 1)))
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				msg := syntheticMessage(userID, commands[rng.Intn(len(commands))])
+
+				began := time.Now()
+				handler.HandleMessage(ctx, api, msg)
+				took := time.Since(began)
+
+				sent.Add(1)
+				mu.Lock()
+				latencies = append(latencies, took)
+				mu.Unlock()
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(int64(1000 + u))
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	waited := mutexWaitSeconds() - waitBefore
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Printf("users:       %d\n", *users)
+	fmt.Printf("updates:     %d in %s\n", sent.Load(), elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput:  %.1f updates/s\n", float64(sent.Load())/elapsed.Seconds())
+	fmt.Printf("latency:     p50=%s p90=%s p99=%s max=%s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), percentile(latencies, 1))
+	fmt.Printf("telegram:    %d API calls\n", fake.CallCount())
+	fmt.Printf("mutex wait:  %.3fs total across goroutines\n", waited)
+
+	if *mutexProfile != "" {
+		f, err := os.Create(*mutexProfile)
+		if err != nil {
+			fatalf("create mutex profile: %v", err)
+		}
+		defer f.Close()
+		if err := pprof.Lookup("mutex").WriteTo(f, 0); err != nil {
+			fatalf("write mutex profile: %v", err)
+		}
+		fmt.Printf("mutex profile written to %s (go tool pprof %s)\n", *mutexProfile, *mutexProfile)
+	}
+}
+
+// syntheticMessage builds a private-chat text message from userID.
+func syntheticMessage(userID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID, FirstName: "load"},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+}
+
+// mutexWaitSeconds reads the runtime's cumulative time goroutines spent blocked on
+// sync.Mutex/RWMutex. The difference across the run approximates store/cache contention.
+func mutexWaitSeconds() float64 {
+	sample := []metrics.Sample{{Name: "/sync/mutex/wait/total:seconds"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return sample[0].Value.Float64()
+}
+
+// percentile returns the p-th percentile (0..1) of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// fatalf prints to stderr even when logging is discarded, then exits.
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "slload: "+format+"\n", args...)
+	os.Exit(1)
+}
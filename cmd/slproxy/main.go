@@ -0,0 +1,316 @@
+// Command slproxy is a small caching reverse proxy for the SL APIs. Several bot
+// instances (or other tools) can point their base URL at it so identical requests
+// hit SL once per TTL. Upstream calls go through a token-bucket rate limiter;
+// when the bucket is empty, stale cached responses are served instead.
+// Responses carry an ETag, and If-None-Match requests get 304 Not Modified.
+// The cache holds at most -max-entries responses, dropping the least recently
+// used first, and forgets a response -max-stale after its TTL ran out.
+//
+//	go run ./cmd/slproxy -addr :8090 -upstream https://transport.integration.sl.se
+//
+// Then configure the bot's client with SetBaseURL("http://localhost:8090/v1").
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahmad/slbot/internal/httpx"
+	"github.com/mahmad/slbot/internal/logging"
+)
+
+// entry is one cached upstream response.
+type entry struct {
+	key         string // request URI, see ServeHTTP
+	status      int
+	contentType string
+	body        []byte
+	etag        string
+	fetched     time.Time
+}
+
+// call is an in-flight upstream fetch that concurrent identical requests wait on.
+type call struct {
+	done  chan struct{}
+	entry *entry
+	err   error
+}
+
+type proxy struct {
+	upstream string
+	client   *http.Client
+	ttl      time.Duration
+	sitesTTL time.Duration
+	limiter  *tokenBucket
+
+	maxEntries int           // cache size cap, see put
+	maxStale   time.Duration // how long past its TTL an entry may still be served
+
+	mu       sync.Mutex
+	cache    map[string]*list.Element // key -> element of lru holding an *entry
+	lru      *list.List               // most recently used first
+	inflight map[string]*call
+}
+
+func main() {
+	logging.Setup(os.Stderr)
+
+	addr := flag.String("addr", "127.0.0.1:8090", "listen address")
+	upstream := flag.String("upstream", "https://transport.integration.sl.se", "SL API origin")
+	ttl := flag.Duration("ttl", 30*time.Second, "cache TTL for departures and other responses")
+	sitesTTL := flag.Duration("sites-ttl", 6*time.Hour, "cache TTL for the sites list")
+	rate := flag.Float64("rate", 5, "upstream requests per second")
+	burst := flag.Int("burst", 10, "upstream burst size")
+	maxEntries := flag.Int("max-entries", 10000, "most responses to cache; the least recently used go first")
+	maxStale := flag.Duration("max-stale", 10*time.Minute, "how long past its TTL a response may be served when rate limited")
+	flag.Parse()
+
+	p := &proxy{
+		upstream: strings.TrimRight(*upstream, "/"),
+		client: &http.Client{
+			Transport: httpx.Chain(nil, httpx.Logging(), httpx.Header("User-Agent", "slproxy")),
+			Timeout:   15 * time.Second,
+		},
+		ttl:      *ttl,
+		sitesTTL: *sitesTTL,
+		limiter:  newTokenBucket(*rate, *burst),
+
+		maxEntries: *maxEntries,
+		maxStale:   *maxStale,
+		cache:      make(map[string]*list.Element),
+		lru:        list.New(),
+		inflight:   make(map[string]*call),
+	}
+	go p.sweepEvery(time.Minute)
+
+	log.Printf("slproxy: proxying %s on http://%s", p.upstream, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
+
+// ServeHTTP answers from cache when fresh, otherwise fetches (once) from upstream.
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET is proxied", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.URL.RequestURI()
+	e, cacheState, err := p.lookup(r.Context(), key)
+	if err != nil {
+		log.Printf("slproxy: %s: %v", key, err)
+		if e == nil {
+			http.Error(w, "upstream unavailable", http.StatusBadGateway)
+			return
+		}
+	}
+	if e == nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limited and nothing cached", http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("ETag", e.etag)
+	w.Header().Set("X-Cache", cacheState)
+	w.Header().Set("Age", fmt.Sprintf("%d", int(time.Since(e.fetched).Seconds())))
+	if match := r.Header.Get("If-None-Match"); match != "" && match == e.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if e.contentType != "" {
+		w.Header().Set("Content-Type", e.contentType)
+	}
+	w.WriteHeader(e.status)
+	if r.Method == http.MethodGet {
+		w.Write(e.body)
+	}
+}
+
+// lookup returns a cache entry for key and how it was obtained ("HIT", "MISS", "STALE").
+// A nil entry with nil error means the rate limiter refused and nothing was cached.
+func (p *proxy) lookup(ctx context.Context, key string) (*entry, string, error) {
+	p.mu.Lock()
+	cached := p.get(key, time.Now())
+	if cached != nil && time.Since(cached.fetched) < p.ttlFor(key) {
+		p.mu.Unlock()
+		return cached, "HIT", nil
+	}
+
+	// Join an in-flight fetch for the same key rather than duplicating it upstream.
+	if c, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return cached, "STALE", ctx.Err()
+		}
+		if c.err != nil {
+			return cached, "STALE", c.err
+		}
+		return c.entry, "MISS", nil
+	}
+
+	if !p.limiter.allow() {
+		p.mu.Unlock()
+		return cached, "STALE", nil
+	}
+
+	c := &call{done: make(chan struct{})}
+	p.inflight[key] = c
+	p.mu.Unlock()
+
+	c.entry, c.err = p.fetch(key)
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	// Only cache successes, so a transient SL error isn't pinned for a whole TTL.
+	if c.err == nil && c.entry.status == http.StatusOK {
+		p.put(c.entry)
+	}
+	p.mu.Unlock()
+	close(c.done)
+
+	if c.err != nil {
+		return cached, "STALE", c.err
+	}
+	return c.entry, "MISS", nil
+}
+
+// fetch performs the upstream GET. It deliberately ignores the client's context so
+// that a disconnecting client doesn't fail the fetch for everyone waiting on it.
+func (p *proxy) fetch(key string) (*entry, error) {
+	resp, err := p.client.Get(p.upstream + key)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	}
+
+	return &entry{
+		key:         key,
+		status:      resp.StatusCode,
+		contentType: resp.Header.Get("Content-Type"),
+		body:        body,
+		etag:        etag,
+		fetched:     time.Now(),
+	}, nil
+}
+
+// get returns the cached entry for key, nil if there is none or it is past
+// serving even stale, and marks it recently used. The caller must hold p.mu.
+func (p *proxy) get(key string, now time.Time) *entry {
+	el, ok := p.cache[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if p.expired(e, now) {
+		p.remove(el)
+		return nil
+	}
+	p.lru.MoveToFront(el)
+	return e
+}
+
+// put caches e, evicting the least recently used entries beyond maxEntries.
+// The caller must hold p.mu.
+func (p *proxy) put(e *entry) {
+	if el, ok := p.cache[e.key]; ok {
+		el.Value = e
+		p.lru.MoveToFront(el)
+	} else {
+		p.cache[e.key] = p.lru.PushFront(e)
+	}
+	for p.maxEntries > 0 && p.lru.Len() > p.maxEntries {
+		p.remove(p.lru.Back())
+	}
+}
+
+// remove drops a cache element. The caller must hold p.mu.
+func (p *proxy) remove(el *list.Element) {
+	p.lru.Remove(el)
+	delete(p.cache, el.Value.(*entry).key)
+}
+
+// expired reports whether e is too old to serve, even when rate limited.
+func (p *proxy) expired(e *entry, now time.Time) bool {
+	return now.Sub(e.fetched) > p.ttlFor(e.key)+p.maxStale
+}
+
+// sweepEvery drops expired entries every interval, so responses nobody asks
+// for again don't sit in memory until the cache fills up.
+func (p *proxy) sweepEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		now := time.Now()
+		p.mu.Lock()
+		for el := p.lru.Front(); el != nil; {
+			next := el.Next()
+			if p.expired(el.Value.(*entry), now) {
+				p.remove(el)
+			}
+			el = next
+		}
+		p.mu.Unlock()
+	}
+}
+
+// ttlFor gives the sites list, which changes rarely, a much longer TTL.
+func (p *proxy) ttlFor(key string) time.Duration {
+	path, _, _ := strings.Cut(key, "?")
+	if strings.HasSuffix(path, "/sites") {
+		return p.sitesTTL
+	}
+	return p.ttl
+}
+
+// tokenBucket is a minimal rate limiter: rate tokens per second, up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
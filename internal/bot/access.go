@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// access is the outcome of checking a user against the access lists.
+type access int
+
+const (
+	accessAllowed    access = iota
+	accessNotAllowed        // not on a configured allowlist: told the bot is private
+	accessBlocked           // blocklisted or banned: ignored silently
+)
+
+// SetAccessLists configures which users may use the bot (used at startup).
+// An empty allow list lets everyone in; block always wins over allow.
+// Admins are never locked out. Runtime bans from /ban are kept in the store.
+func (h *Handler) SetAccessLists(allow, block []int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.allowlist = make(map[int64]bool, len(allow))
+	for _, id := range allow {
+		h.allowlist[id] = true
+	}
+	h.blocklist = make(map[int64]bool, len(block))
+	for _, id := range block {
+		h.blocklist[id] = true
+	}
+}
+
+// checkAccess decides whether an update from info.UserID should be handled.
+func (h *Handler) checkAccess(info RequestInfo) access {
+	if info.Admin {
+		return accessAllowed
+	}
+
+	h.mu.RLock()
+	blocked := h.blocklist[info.UserID]
+	notAllowed := len(h.allowlist) > 0 && !h.allowlist[info.UserID]
+	h.mu.RUnlock()
+
+	switch {
+	case blocked || h.userStore.IsBanned(info.UserID):
+		return accessBlocked
+	case notAllowed:
+		return accessNotAllowed
+	default:
+		return accessAllowed
+	}
+}
+
+// handleBan bans or unbans a user ID (admin only).
+func (h *Handler) handleBan(api *tgbotapi.BotAPI, chatID int64, args string, ban bool) {
+	command := "/unban"
+	if ban {
+		command = "/ban"
+	}
+
+	userID, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+	if err != nil {
+		h.sendMessage(api, chatID, fmt.Sprintf("❓ Usage: %s <user id>", command))
+		return
+	}
+	if ban && h.isAdmin(userID) {
+		h.sendMessage(api, chatID, "❌ Admins can't be banned.")
+		return
+	}
+
+	if ban {
+		err = h.userStore.Ban(userID)
+	} else {
+		err = h.userStore.Unban(userID)
+	}
+	if err != nil {
+		log.Printf("handleBan: %s %d: %v", command, userID, err)
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	log.Printf("handleBan: %s %d", command, userID)
+	if ban {
+		h.sendMessage(api, chatID, fmt.Sprintf("⛔ User %d banned.", userID))
+	} else {
+		h.sendMessage(api, chatID, fmt.Sprintf("✅ User %d unbanned.", userID))
+	}
+}
+
+// handleBanned lists users banned at runtime (admin only).
+func (h *Handler) handleBanned(api *tgbotapi.BotAPI, chatID int64) {
+	banned := h.userStore.Banned()
+	if len(banned) == 0 {
+		h.sendMessage(api, chatID, "No banned users.")
+		return
+	}
+
+	ids := make([]string, len(banned))
+	for i, id := range banned {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	h.sendMessage(api, chatID, "⛔ Banned: "+strings.Join(ids, ", "))
+}
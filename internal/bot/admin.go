@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SetAdmins configures which Telegram user IDs may run admin commands (used at startup).
+func (h *Handler) SetAdmins(userIDs []int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.admins = make(map[int64]bool, len(userIDs))
+	for _, id := range userIDs {
+		h.admins[id] = true
+	}
+}
+
+// isAdmin reports whether userID was configured via SetAdmins.
+func (h *Handler) isAdmin(userID int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.admins[userID]
+}
+
+// handleQuota shows upstream SL call counts against their soft limits (admin only).
+func (h *Handler) handleQuota(api *tgbotapi.BotAPI, chatID int64) {
+	var b strings.Builder
+	b.WriteString("📊 Upstream SL calls\n")
+
+	usage := h.slClient.Usage()
+	if len(usage) == 0 {
+		b.WriteString("No calls yet.\n")
+	}
+	for _, u := range usage {
+		fmt.Fprintf(&b, "%s: %d today", u.Endpoint, u.Today)
+		if u.SoftLimit > 0 {
+			fmt.Fprintf(&b, " / %d", u.SoftLimit)
+		}
+		fmt.Fprintf(&b, " (total %d)", u.Total)
+		if u.Degraded {
+			b.WriteString(" ⚠️ degraded")
+		}
+		b.WriteString("\n")
+	}
+
+	if health := h.slClient.BaseURLHealth(); len(health) > 1 {
+		b.WriteString("\nEndpoints:\n")
+		for _, base := range sortedKeys(health) {
+			state := "✅"
+			if !health[base] {
+				state = "❌ failing over"
+			}
+			fmt.Fprintf(&b, "%s %s\n", base, state)
+		}
+	}
+
+	stats := h.slClient.ConnStats()
+	fmt.Fprintf(&b, "\nConnections: %d reused / %d new", stats.Reused, stats.New)
+
+	h.sendMessage(api, chatID, b.String())
+}
+
+// handleLimits shows or changes the subscription, alert and alarm limits (admin only):
+// "/limits" shows them, "/limits <per-user> <total>" sets the subscription limits,
+// "/limits alerts <per-user>" the disruption alert limit and "/limits alarms <per-user>"
+// the departure alarm limit (0 = unlimited).
+func (h *Handler) handleLimits(api *tgbotapi.BotAPI, chatID int64, args string) {
+	limits := h.userStore.Limits()
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		total := 0
+		for _, subs := range h.userStore.AllSubscriptions() {
+			total += len(subs)
+		}
+		h.sendMessage(api, chatID, fmt.Sprintf("📏 Subscriptions: %s per user, %s total (%d in use)\nDisruption alerts: %s per user\nDeparture alarms: %s per user",
+			formatLimit(limits.SubscriptionsPerUser), formatLimit(limits.SubscriptionsTotal), total,
+			formatLimit(limits.DeviationAlertsPerUser), formatLimit(limits.AlarmsPerUser)))
+		return
+	}
+
+	usage := "❓ Usage: /limits <per-user> <total>, /limits alerts <per-user> or /limits alarms <per-user>, 0 for unlimited"
+	if len(fields) != 2 {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	var confirm string
+	if fields[0] == "alerts" || fields[0] == "alarms" {
+		perUser, err := strconv.Atoi(fields[1])
+		if err != nil || perUser < 0 {
+			h.sendMessage(api, chatID, usage)
+			return
+		}
+		if fields[0] == "alerts" {
+			limits.DeviationAlertsPerUser = perUser
+			confirm = fmt.Sprintf("✅ Disruption alert limit set to %s per user", formatLimit(perUser))
+		} else {
+			limits.AlarmsPerUser = perUser
+			confirm = fmt.Sprintf("✅ Departure alarm limit set to %s per user", formatLimit(perUser))
+		}
+	} else {
+		perUser, err1 := strconv.Atoi(fields[0])
+		total, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil || perUser < 0 || total < 0 {
+			h.sendMessage(api, chatID, usage)
+			return
+		}
+		limits.SubscriptionsPerUser, limits.SubscriptionsTotal = perUser, total
+		confirm = fmt.Sprintf("✅ Subscription limits set to %s per user, %s total", formatLimit(perUser), formatLimit(total))
+	}
+
+	if err := h.userStore.SetLimits(limits); err != nil {
+		log.Printf("handleLimits: error saving limits: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving limits. Try again later.")
+		return
+	}
+	h.sendMessage(api, chatID, confirm)
+}
+
+func formatLimit(n int) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(n)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,297 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/webhook"
+)
+
+// Departure alarms go off alarmBuffer plus the user's walking time (see /walk)
+// before the departure. Walking times are capped at maxWalk.
+const (
+	alarmBuffer = 2 * time.Minute
+	maxWalk     = 60
+)
+
+// alarm is a one-shot reminder to leave for one departure. Alarms live in
+// memory only: they are minutes to an hour ahead and a restart just drops them.
+// dep is updated by RunAlarms under h.mu.
+type alarm struct {
+	userID, chatID int64
+	siteID         string
+	key            string // departureKey of the departure
+	dep            sl.Departure
+	lead           time.Duration // how long before dep.Expected to go off
+}
+
+func (a *alarm) fireAt() time.Time {
+	return a.dep.Expected.Add(-a.lead)
+}
+
+// departureKey identifies a departure across responses: by journey when SL
+// gives one, otherwise by line and scheduled time.
+func departureKey(dep sl.Departure) string {
+	if dep.Journey.ID != 0 {
+		return "j" + strconv.FormatInt(dep.Journey.ID, 10)
+	}
+	return fmt.Sprintf("t%d|%s", dep.Scheduled.Unix(), dep.Line)
+}
+
+// sendBoard sends a departure board for dest with an alarm button under each
+// shown departure and a refresh button, and remembers what the board shows so
+// the buttons can repeat it. towards is the destination stop the board is
+// limited to, "" for none.
+func (h *Handler) sendBoard(api *tgbotapi.BotAPI, chatID int64, siteID, dest, towards, text string, shown []sl.Departure) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	if markup := boardMarkup(siteID, dest, shown); markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	sent, err := api.Send(msg)
+	if err != nil {
+		log.Printf("sendBoard: error sending message: %v", err)
+		return
+	}
+	if err := h.userStore.SetMessageContext(chatID, sent.MessageID, boardQuery(siteID, dest, towards), boardContextTTL); err != nil {
+		log.Printf("sendBoard: error saving message context: %v", err)
+	}
+}
+
+// boardMarkup builds a board's buttons: one ⏰ per shown departure, then 🔄 Refresh
+// and, on home and work boards, ⇄ for the other direction.
+// It returns nil if siteID isn't a numeric SL site ID.
+func boardMarkup(siteID, dest string, shown []sl.Departure) *tgbotapi.InlineKeyboardMarkup {
+	id, err := strconv.Atoi(siteID)
+	if err != nil {
+		return nil
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(shown) > 0 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, dep := range shown {
+			data := callbackData{Version: callbackVersion, Action: "alarm", SiteID: id, Arg: departureKey(dep)}
+			label := fmt.Sprintf("⏰ %s %s", dep.Line, format.Clock(dep.Expected))
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, data.encode()))
+		}
+		rows = append(rows, row)
+	}
+	controls := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔄 Refresh", encodeCallback("refresh", 0, 0)),
+	)
+	if other, ok := reverseDest[dest]; ok {
+		controls = append(controls, tgbotapi.NewInlineKeyboardButtonData("⇄ To "+other, encodeCallback("swap", 0, 0)))
+	}
+	rows = append(rows, controls)
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// handleAlarmCallback sets (or, pressed again, cancels) an alarm for the tapped departure.
+func (h *Handler) handleAlarmCallback(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, data callbackData) {
+	chatID := callback.Message.Chat.ID
+	userID := callback.From.ID
+	siteID := strconv.Itoa(data.SiteID)
+	id := fmt.Sprintf("%d|%s", userID, data.Arg)
+
+	h.mu.Lock()
+	existing, ok := h.alarms[id]
+	var cancelled string
+	if ok {
+		cancelled = fmt.Sprintf("🔕 Alarm for %s %s cancelled.", existing.dep.Line, format.Clock(existing.dep.Expected))
+		delete(h.alarms, id)
+	}
+	h.mu.Unlock()
+	if ok {
+		h.sendMessage(api, chatID, cancelled)
+		return
+	}
+
+	departures, err := h.slClient.GetDepartures(ctx, siteID)
+	if err != nil {
+		log.Printf("handleAlarmCallback: site=%s: %v", siteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+	dep, found := findDeparture(departures, data.Arg)
+	now := time.Now()
+	if !found || !dep.Expected.After(now) {
+		h.sendMessage(api, chatID, "❌ That departure has already left.")
+		return
+	}
+
+	walk := time.Duration(h.userStore.GetPrefs(userID).WalkMinutes[siteID]) * time.Minute
+	a := &alarm{userID: userID, chatID: chatID, siteID: siteID, key: data.Arg, dep: dep, lead: walk + alarmBuffer}
+	if !a.fireAt().After(now) {
+		h.sendMessage(api, chatID, fmt.Sprintf("🏃 No time for an alarm: %s leaves at %s. Go now!", dep.Line, format.Clock(dep.Expected)))
+		return
+	}
+
+	if !h.armAlarm(a) {
+		h.sendMessage(api, chatID, tooManyAlarmsText(h.userStore.Limits().AlarmsPerUser))
+		return
+	}
+	h.sendMessage(api, chatID, alarmSetText(a, walk))
+}
+
+// armAlarm adds a unless its user already has Limits.AlarmsPerUser alarms.
+// Re-arming an alarm that is already set replaces it and always succeeds.
+func (h *Handler) armAlarm(a *alarm) bool {
+	max := h.userStore.Limits().AlarmsPerUser
+	id := fmt.Sprintf("%d|%s", a.userID, a.key)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, rearm := h.alarms[id]; !rearm && max > 0 {
+		mine := 0
+		for _, other := range h.alarms {
+			if other.userID == a.userID {
+				mine++
+			}
+		}
+		if mine >= max {
+			return false
+		}
+	}
+	h.alarms[id] = a
+	return true
+}
+
+func tooManyAlarmsText(max int) string {
+	return fmt.Sprintf("❌ You already have %d departure alarms, the maximum. See /leave to list or cancel them.", max)
+}
+
+// alarmSetText confirms an alarm that was just set.
+func alarmSetText(a *alarm, walk time.Duration) string {
+	text := fmt.Sprintf("⏰ I'll remind you at %s, %d min before %s → %s leaves at %s.",
+		format.Clock(a.fireAt()), int(a.lead.Minutes()), a.dep.Line, a.dep.Direction, format.Clock(a.dep.Expected))
+	if walk == 0 {
+		text += " Set your walk to this stop with /walk."
+	}
+	return text
+}
+
+func findDeparture(departures []sl.Departure, key string) (sl.Departure, bool) {
+	for _, dep := range departures {
+		if departureKey(dep) == key {
+			return dep, true
+		}
+	}
+	return sl.Departure{}, false
+}
+
+// RunAlarms keeps every armed alarm's departure up to date and sends the ones
+// that are due. Each run re-fetches the departures at every stop with an armed
+// alarm, once per stop, so a bus that is delayed or moved earlier moves its
+// alarm with it. If a fetch fails the alarm keeps its last known time.
+// Alarms whose departure has left are dropped. Register it with a scheduler.Scheduler.
+func (h *Handler) RunAlarms(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	h.mu.RLock()
+	bySite := make(map[string][]*alarm)
+	for _, a := range h.alarms {
+		bySite[a.siteID] = append(bySite[a.siteID], a)
+	}
+	h.mu.RUnlock()
+
+	for siteID, alarms := range bySite {
+		departures, err := h.slClient.GetDepartures(ctx, siteID)
+		if err != nil {
+			log.Printf("RunAlarms: site=%s: %v", siteID, err)
+		}
+		for _, a := range alarms {
+			if err == nil {
+				if dep, ok := findDeparture(departures, a.key); ok {
+					h.mu.Lock()
+					a.dep = dep
+					h.mu.Unlock()
+				}
+			}
+			h.checkAlarm(api, a, now)
+		}
+	}
+}
+
+// checkAlarm sends a if it is due and drops it once sent or once its departure
+// has left. An alarm cancelled or replaced since RunAlarms picked it up is skipped.
+func (h *Handler) checkAlarm(api *tgbotapi.BotAPI, a *alarm, now time.Time) {
+	id := fmt.Sprintf("%d|%s", a.userID, a.key)
+
+	h.mu.Lock()
+	if h.alarms[id] != a {
+		h.mu.Unlock()
+		return
+	}
+	left := !a.dep.Expected.After(now)
+	due := !now.Before(a.fireAt())
+	if left || due {
+		delete(h.alarms, id)
+	}
+	h.mu.Unlock()
+
+	if !left && due {
+		text := fmt.Sprintf("⏰ Time to go! %s → %s leaves %s at %s%s.", a.dep.Line, a.dep.Direction,
+			a.dep.StopArea.Name, format.Clock(a.dep.Expected), format.Platform(format.English, a.dep.StopPoint.Designation))
+		if a.dep.Journey.State == "CANCELLED" {
+			text = fmt.Sprintf("❌ %s → %s at %s has been cancelled.", a.dep.Line, a.dep.Direction, format.Clock(a.dep.Scheduled))
+		}
+		h.sendPush(api, a.userID, a.chatID, "alarm", text)
+		h.emit(webhook.Event{
+			Type:   webhook.EventAlertFired,
+			UserID: a.userID,
+			ChatID: a.chatID,
+			Data: map[string]any{"kind": "alarm", "siteId": a.siteID, "line": a.dep.Line,
+				"direction": a.dep.Direction, "expected": a.dep.Expected, "cancelled": a.dep.Journey.State == "CANCELLED"},
+		})
+	}
+}
+
+// handleWalk saves the walking time to the home or work stop: "/walk home 5".
+func (h *Handler) handleWalk(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	usage := fmt.Sprintf("❓ Usage: /walk home|work <minutes, up to %d>, e.g. /walk home 5", maxWalk)
+
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+	siteID, ok := h.savedSiteID(h.userStore.GetPrefs(userID), fields[0])
+	minutes, err := strconv.Atoi(fields[1])
+	if !ok || err != nil || minutes < 0 || minutes > maxWalk {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	if err := h.userStore.SetWalkTime(userID, siteID, minutes); err != nil {
+		log.Printf("handleWalk: error saving walk time: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ Walk to %s: %d min. Departure alarms there go off %d min ahead.",
+		fields[0], minutes, minutes+int(alarmBuffer.Minutes())))
+}
+
+// savedSiteID resolves "home" or "work" to the user's stop, falling back to the bot default.
+func (h *Handler) savedSiteID(prefs store.UserPreferences, label string) (string, bool) {
+	switch label {
+	case "home":
+		if prefs.HomeSiteID != "" {
+			return prefs.HomeSiteID, true
+		}
+		return h.homeSiteID, true
+	case "work":
+		if prefs.WorkSiteID != "" {
+			return prefs.WorkSiteID, true
+		}
+		return h.workSiteID, true
+	}
+	return "", false
+}
@@ -0,0 +1,258 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/webhook"
+)
+
+// parseAlertLine reads the target of "/subscribe line 515" or "/subscribe commute":
+// a line designation, or "" for the whole commute.
+func parseAlertLine(args string) (line string, ok bool) {
+	args = strings.TrimSpace(args)
+	switch {
+	case args == "commute" || args == "my commute":
+		return "", true
+	case strings.HasPrefix(args, "line "):
+		line = strings.TrimSpace(strings.TrimPrefix(args, "line "))
+		return strings.ToUpper(line), line != "" && !strings.ContainsAny(line, " \t")
+	}
+	return "", false
+}
+
+// alertLabel names what an alert watches, for messages and /subscriptions.
+func alertLabel(line string) string {
+	if line == "" {
+		return "your commute"
+	}
+	return "line " + line
+}
+
+// handleSubscribeAlert adds a deviation alert: "/subscribe line 515" or "/subscribe commute".
+// Deviations already showing at the user's stops count as sent, so only ones
+// that appear later are pushed.
+func (h *Handler) handleSubscribeAlert(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	line, ok := parseAlertLine(args)
+	if !ok {
+		h.sendMessage(api, chatID, "❓ Usage: /subscribe line <line> or /subscribe commute")
+		return
+	}
+
+	prefs := h.userStore.GetPrefs(userID)
+	for _, existing := range prefs.DeviationAlerts {
+		if existing.Line == line {
+			h.sendMessage(api, chatID, fmt.Sprintf("You already get disruption alerts for %s.", alertLabel(line)))
+			return
+		}
+	}
+
+	now := time.Now()
+	sent := make(map[string]time.Time)
+	for _, siteID := range h.commuteSites(prefs) {
+		departures, err := h.slClient.GetDepartures(ctx, siteID)
+		if err != nil {
+			// Worst case the first poll repeats what is already showing.
+			log.Printf("handleSubscribeAlert: site=%s: %v", siteID, err)
+			continue
+		}
+		for key := range stopDeviations(departures, line) {
+			sent[key] = now
+		}
+	}
+
+	alert, err := h.userStore.AddDeviationAlert(userID, store.DeviationAlert{ChatID: chatID, Line: line, Sent: sent})
+	switch {
+	case errors.Is(err, store.ErrUserLimit):
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ You already have %d disruption alerts, the maximum. Remove one first; see /subscriptions.",
+			h.userStore.Limits().DeviationAlertsPerUser))
+		return
+	case err != nil:
+		log.Printf("handleSubscribeAlert: error saving alert: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving subscription. Try again later.")
+		return
+	}
+
+	log.Printf("handleSubscribeAlert: user=%d alert=%d line=%q", userID, alert.ID, line)
+	h.sendMessage(api, chatID, fmt.Sprintf("⚠️ You'll get new disruptions for %s at your home and work stops. Stop with /unsubscribe %s.",
+		alertLabel(line), unsubscribeArg(line)))
+}
+
+// handleUnsubscribeAlert removes the deviation alert matching "line 515" or "commute".
+func (h *Handler) handleUnsubscribeAlert(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	line, ok := parseAlertLine(args)
+	if !ok {
+		h.sendMessage(api, chatID, "❓ Usage: /unsubscribe line <line> or /unsubscribe commute")
+		return
+	}
+
+	for _, alert := range h.userStore.GetPrefs(userID).DeviationAlerts {
+		if alert.Line != line {
+			continue
+		}
+		if err := h.userStore.RemoveDeviationAlert(userID, alert.ID); err != nil {
+			log.Printf("handleUnsubscribeAlert: %v", err)
+			h.sendMessage(api, chatID, "❌ Error removing subscription. Try again later.")
+			return
+		}
+		h.sendMessage(api, chatID, fmt.Sprintf("🔕 No more disruption alerts for %s.", alertLabel(line)))
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("❌ You have no disruption alerts for %s.", alertLabel(line)))
+}
+
+// deviationKey identifies a deviation message across polls and departures.
+func deviationKey(message string) string {
+	sum := fnv.New64a()
+	sum.Write([]byte(message))
+	return strconv.FormatUint(sum.Sum64(), 36)
+}
+
+// activeDeviation is one deviation found at a stop, with the lines it was on.
+type activeDeviation struct {
+	message string
+	stop    string
+	lines   []string
+}
+
+// stopDeviations collects the deviations on departures, on line only unless it is "".
+func stopDeviations(departures []sl.Departure, line string) map[string]*activeDeviation {
+	found := make(map[string]*activeDeviation)
+	for _, dep := range departures {
+		if line != "" && !strings.EqualFold(dep.Line, line) {
+			continue
+		}
+		for _, dev := range dep.Deviations {
+			if dev.Message == "" {
+				continue
+			}
+			key := deviationKey(dev.Message)
+			d, ok := found[key]
+			if !ok {
+				d = &activeDeviation{message: dev.Message, stop: dep.StopArea.Name}
+				found[key] = d
+			}
+			if !containsString(d.lines, dep.Line) {
+				d.lines = append(d.lines, dep.Line)
+			}
+		}
+	}
+	return found
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RunDeviationAlerts pushes deviations that have newly appeared on the
+// departures from each alert owner's home and work stops. Each deviation is
+// pushed once per alert. Register it with a scheduler.Scheduler:
+//
+//	sched.Add("deviation-alerts", func(ctx context.Context, now time.Time) {
+//		handler.RunDeviationAlerts(ctx, api, now)
+//	})
+func (h *Handler) RunDeviationAlerts(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	// Several users usually share stops; fetch each once per run.
+	fetched := make(map[string][]sl.Departure)
+	departuresAt := func(siteID string) ([]sl.Departure, bool) {
+		if deps, ok := fetched[siteID]; ok {
+			return deps, deps != nil
+		}
+		deps, err := h.slClient.GetDepartures(ctx, siteID)
+		if err != nil {
+			log.Printf("RunDeviationAlerts: site=%s: %v", siteID, err)
+		}
+		fetched[siteID] = deps
+		return deps, err == nil
+	}
+
+	for userID, alerts := range h.userStore.AllDeviationAlerts() {
+		if h.checkAccess(RequestInfo{UserID: userID, Admin: h.isAdmin(userID)}) != accessAllowed {
+			continue
+		}
+		prefs := h.userStore.GetPrefs(userID)
+		if !prefs.Notifications.Enabled(store.NotifyDisruptions) {
+			continue
+		}
+
+		sites := h.commuteSites(prefs)
+		for _, alert := range alerts {
+			for _, siteID := range sites {
+				departures, ok := departuresAt(siteID)
+				if !ok {
+					continue
+				}
+				found := stopDeviations(departures, alert.Line)
+				keys := make([]string, 0, len(found))
+				for key := range found {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+
+				for _, key := range keys {
+					if _, sent := alert.Sent[key]; sent {
+						continue
+					}
+					d := found[key]
+					if d.stop == "" {
+						d.stop = h.siteNameByID(ctx, siteID)
+					}
+					text := fmt.Sprintf("⚠️ Disruption on %s at %s:\n%s\n\nStop these with /unsubscribe %s",
+						strings.Join(d.lines, ", "), escapeMarkdown(d.stop), escapeMarkdown(d.message), unsubscribeArg(alert.Line))
+					h.sendPush(api, userID, alert.ChatID, "disruption", text)
+					h.emit(webhook.Event{
+						Type:   webhook.EventAlertFired,
+						UserID: userID,
+						ChatID: alert.ChatID,
+						Data: map[string]any{"kind": "deviation", "siteId": siteID, "stop": d.stop,
+							"lines": d.lines, "message": d.message, "alertId": alert.ID},
+					})
+
+					if err := h.userStore.MarkDeviationSent(userID, alert.ID, key, now); err != nil {
+						log.Printf("RunDeviationAlerts: error marking alert %d: %v", alert.ID, err)
+					}
+					if alert.Sent == nil {
+						alert.Sent = make(map[string]time.Time)
+					}
+					// The same notice is often on both stops; send it once.
+					alert.Sent[key] = now
+				}
+			}
+		}
+	}
+}
+
+// commuteSites returns the user's home and work stops, falling back to the bot
+// defaults, without duplicates.
+func (h *Handler) commuteSites(prefs store.UserPreferences) []string {
+	var sites []string
+	for _, label := range []string{"home", "work"} {
+		if siteID, _ := h.savedSiteID(prefs, label); siteID != "" && !containsString(sites, siteID) {
+			sites = append(sites, siteID)
+		}
+	}
+	return sites
+}
+
+// unsubscribeArg is what /unsubscribe takes to remove an alert for line.
+func unsubscribeArg(line string) string {
+	if line == "" {
+		return "commute"
+	}
+	return "line " + line
+}
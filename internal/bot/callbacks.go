@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackVersion is bumped whenever callbackData changes incompatibly, so old
+// buttons still sitting in chats can be recognised.
+const callbackVersion = 1
+
+// maxCallbackData is Telegram's limit on a button's callback data, in bytes.
+const maxCallbackData = 64
+
+// callbackData is the payload of an inline button, encoded as compact JSON,
+// e.g. {"v":1,"a":"home","u":42,"s":9192}. Keys are one letter to stay well
+// under maxCallbackData; add new fields with omitempty.
+type callbackData struct {
+	Version int    `json:"v"`
+	Action  string `json:"a"`           // key into the handler's callback registry
+	UserID  int64  `json:"u,omitempty"` // user the button was offered to
+	SiteID  int    `json:"s,omitempty"`
+	Arg     string `json:"x,omitempty"` // action-specific, e.g. a notification category
+}
+
+// encodeCallback renders a button payload. Oversized payloads are logged, since
+// Telegram would reject the whole message.
+func encodeCallback(action string, userID int64, siteID int) string {
+	return callbackData{Version: callbackVersion, Action: action, UserID: userID, SiteID: siteID}.encode()
+}
+
+// encode renders d as a button payload; see encodeCallback.
+func (d callbackData) encode() string {
+	data, _ := json.Marshal(d)
+	if len(data) > maxCallbackData {
+		log.Printf("encodeCallback: %s payload is %d bytes, over Telegram's %d", d.Action, len(data), maxCallbackData)
+	}
+	return string(data)
+}
+
+// decodeCallback parses a button payload. Buttons sent before the JSON format
+// used "<action>_<userID>_<siteID>"; those decode as version 0.
+func decodeCallback(raw string) (callbackData, error) {
+	var data callbackData
+	if strings.HasPrefix(raw, "{") {
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return data, fmt.Errorf("decode callback: %w", err)
+		}
+		if data.Version > callbackVersion {
+			return data, fmt.Errorf("callback version %d is newer than %d", data.Version, callbackVersion)
+		}
+		return data, nil
+	}
+
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 {
+		return data, fmt.Errorf("invalid legacy callback %q", raw)
+	}
+	userID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return data, fmt.Errorf("invalid userID %q", parts[1])
+	}
+	siteID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return data, fmt.Errorf("invalid siteID %q", parts[2])
+	}
+	return callbackData{Action: parts[0], UserID: userID, SiteID: siteID}, nil
+}
+
+// callbackHandler handles one kind of button press.
+type callbackHandler func(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, data callbackData)
+
+// registerCallbacks fills the callback registry; new button kinds are added here.
+func (h *Handler) registerCallbacks() {
+	h.callbacks = map[string]callbackHandler{
+		"home": func(ctx context.Context, api *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery, data callbackData) {
+			h.handleSiteChoice(api, cb, "home", data)
+		},
+		"work": func(ctx context.Context, api *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery, data callbackData) {
+			h.handleSiteChoice(api, cb, "work", data)
+		},
+		"homeall": func(ctx context.Context, api *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery, data callbackData) {
+			h.expandSiteButtons(api, cb, "home", data.UserID, h.pendingHome)
+		},
+		"workall": func(ctx context.Context, api *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery, data callbackData) {
+			h.expandSiteButtons(api, cb, "work", data.UserID, h.pendingWork)
+		},
+		"notify":  h.handleNotifyToggle,
+		"alarm":   h.handleAlarmCallback,
+		"refresh": h.handleRefresh,
+		"swap":    h.handleSwap,
+		"from": func(ctx context.Context, api *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery, data callbackData) {
+			h.handleFromCallback(ctx, api, cb, data.UserID, data.SiteID)
+		},
+	}
+}
+
+// handleSiteChoice saves the home or work stop picked from a disambiguation keyboard.
+func (h *Handler) handleSiteChoice(api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, kind string, data callbackData) {
+	pending, save, label := h.pendingHome, h.userStore.SetHome, "Home"
+	if kind == "work" {
+		pending, save, label = h.pendingWork, h.userStore.SetWork, "Work"
+	}
+
+	h.mu.RLock()
+	matches := pending[data.UserID]
+	h.mu.RUnlock()
+
+	// Find the selected site by ID
+	var siteName string
+	for _, site := range matches {
+		if site.SiteID == data.SiteID {
+			siteName = site.Name
+			break
+		}
+	}
+
+	if siteName == "" {
+		h.sendMessage(api, callback.Message.Chat.ID, "❌ Site not found in pending selections.")
+		return
+	}
+
+	// Save the preference
+	if err := save(data.UserID, strconv.Itoa(data.SiteID)); err != nil {
+		log.Printf("handleSiteChoice: error setting %s: %v", kind, err)
+		h.sendMessage(api, callback.Message.Chat.ID, "❌ Error saving preference.")
+		return
+	}
+
+	// Clean up pending
+	h.mu.Lock()
+	delete(pending, data.UserID)
+	h.mu.Unlock()
+
+	// Edit the message to show confirmation
+	edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
+		fmt.Sprintf("✅ %s set to: %s", label, siteName))
+	if _, err := api.Send(edit); err != nil {
+		log.Printf("handleSiteChoice: error editing message: %v", err)
+	}
+	log.Printf("handleSiteChoice: saved %s site %d (%s) for user %d", kind, data.SiteID, siteName, data.UserID)
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// Formatter variants for departure boards.
+const (
+	formatterStable = "stable" // sl.FormatDepartures
+	formatterCanary = "canary" // sl.FormatDeparturesRelative
+)
+
+// canary rolls a new board layout out to a percentage of users and collects
+// their /feedback. Users are bucketed by a hash of their ID, so the same user
+// keeps seeing the same layout while the percentage is unchanged.
+type canary struct {
+	mu       sync.Mutex
+	percent  int                       // 0 disables the canary
+	feedback map[string]map[string]int // variant -> "good"/"bad" -> count
+}
+
+func newCanary() *canary {
+	return &canary{feedback: make(map[string]map[string]int)}
+}
+
+// variant returns which formatter id should see.
+func (c *canary) variant(id int64) string {
+	c.mu.Lock()
+	percent := c.percent
+	c.mu.Unlock()
+
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%d", id)
+	if int(hash.Sum32()%100) < percent {
+		return formatterCanary
+	}
+	return formatterStable
+}
+
+// SetFormatterCanary shows the new board layout to percent of users (0–100).
+func (h *Handler) SetFormatterCanary(percent int) {
+	h.canary.mu.Lock()
+	defer h.canary.mu.Unlock()
+
+	h.canary.percent = max(0, min(100, percent))
+}
+
+// formatBoard renders departures with the layout chosen for the current user,
+// each followed by its most important deviation (see deviationLines). Users
+// who picked the lines layout with /layout get formatLineBoard instead.
+// Scheduled pushes carry no request info and are bucketed by chat instead.
+func (h *Handler) formatBoard(ctx context.Context, chatID int64, siteID string, departures []sl.Departure, count int) string {
+	id := chatID
+	if info, ok := RequestInfoFrom(ctx); ok && info.UserID != 0 {
+		id = info.UserID
+	}
+	th := h.thresholdsFor(id)
+	// A layout the user picked wins over the canary trial.
+	if h.userStore.GetPrefs(id).Layout == store.LayoutLines {
+		return formatLineBoard(siteID, departures, time.Now(), th)
+	}
+	canary := h.canary.variant(id) == formatterCanary
+
+	var b strings.Builder
+	now := time.Now()
+	seen := make(map[string]bool)
+	more := false
+	for _, dep := range departures[:min(count, len(departures))] {
+		if canary {
+			b.WriteString(sl.FormatDepartureRelative(dep, now, th) + "\n")
+		} else {
+			b.WriteString(sl.FormatDeparture(dep, th) + "\n")
+		}
+		lines, hidden := deviationLines(dep, seen)
+		b.WriteString(lines)
+		more = more || hidden
+	}
+	if more {
+		fmt.Fprintf(&b, "ℹ️ More disruption info: /deviations\\_%s\n", siteID)
+	}
+
+	if canary {
+		b.WriteString("🧪 Trying a new layout. Tell us: /feedback good|bad [comment]\n")
+	}
+	return b.String()
+}
+
+// handleFeedback records "/feedback good|bad [comment]" against the user's current layout.
+func (h *Handler) handleFeedback(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	verdict, comment, _ := strings.Cut(strings.TrimSpace(args), " ")
+	verdict = strings.ToLower(verdict)
+	if verdict != "good" && verdict != "bad" {
+		h.sendMessage(api, chatID, "❓ Usage: /feedback good|bad [comment]")
+		return
+	}
+
+	variant := h.canary.variant(userID)
+	h.canary.mu.Lock()
+	if h.canary.feedback[variant] == nil {
+		h.canary.feedback[variant] = make(map[string]int)
+	}
+	h.canary.feedback[variant][verdict]++
+	h.canary.mu.Unlock()
+
+	log.Printf("handleFeedback: user=%d layout=%s verdict=%s comment=%q", userID, variant, verdict, strings.TrimSpace(comment))
+	h.sendMessage(api, chatID, "🙏 Thanks for the feedback!")
+}
+
+// handleCanary shows the canary split and feedback, or changes it with
+// "/canary <percent>" (admin only).
+func (h *Handler) handleCanary(api *tgbotapi.BotAPI, chatID int64, args string) {
+	if args = strings.TrimSpace(args); args != "" {
+		percent, err := strconv.Atoi(args)
+		if err != nil || percent < 0 || percent > 100 {
+			h.sendMessage(api, chatID, "❓ Usage: /canary [0-100]")
+			return
+		}
+		h.SetFormatterCanary(percent)
+		log.Printf("handleCanary: canary layout now at %d%%", percent)
+	}
+
+	h.canary.mu.Lock()
+	defer h.canary.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧪 New board layout shown to %d%% of users\n", h.canary.percent)
+	for _, variant := range []string{formatterStable, formatterCanary} {
+		votes := h.canary.feedback[variant]
+		fmt.Fprintf(&b, "%s: 👍 %d 👎 %d\n", variant, votes["good"], votes["bad"])
+	}
+	h.sendMessage(api, chatID, b.String())
+}
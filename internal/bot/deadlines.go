@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Per-command deadlines. Commands that may download the full sites list get the
+// longest; departure boards need one upstream call; everything else is local.
+const (
+	defaultCommandTimeout  = 10 * time.Second
+	boardCommandTimeout    = 15 * time.Second
+	siteSearchTimeout      = 30 * time.Second
+	callbackCommandTimeout = 15 * time.Second
+)
+
+// commandTimeouts maps a message's first word to its deadline.
+var commandTimeouts = map[string]time.Duration{
+	"to":             boardCommandTimeout,
+	"from":           boardCommandTimeout,
+	"/direction":     boardCommandTimeout,
+	"/leave":         boardCommandTimeout,
+	"/prefs":         siteSearchTimeout,
+	"/sethome":       siteSearchTimeout,
+	"/setwork":       siteSearchTimeout,
+	"/place":         siteSearchTimeout,
+	"/subscribe":     siteSearchTimeout,
+	"/note":          siteSearchTimeout,
+	"/notes":         siteSearchTimeout,
+	"/guestlink":     siteSearchTimeout,
+	"/rawdepartures": siteSearchTimeout,
+	"/deviations":    siteSearchTimeout,
+	"/departures":    siteSearchTimeout,
+	"":               siteSearchTimeout, // shared locations (no text), see handleLocation
+}
+
+// Progress messages: shown once a command has run for progressAfter, then
+// edited every progressEvery until the command finishes and it is deleted.
+const (
+	progressAfter = 3 * time.Second
+	progressEvery = 5 * time.Second
+)
+
+// commandTimeout returns the deadline for a normalized message text.
+func commandTimeout(text string) time.Duration {
+	command, _, _ := strings.Cut(text, " ")
+	if d, ok := commandTimeouts[command]; ok {
+		return d
+	}
+	return defaultCommandTimeout
+}
+
+// startProgress tells the user a slow command is still running. Call the returned
+// function when the command is done; it removes the progress message if one was sent.
+func (h *Handler) startProgress(api *tgbotapi.BotAPI, chatID int64) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		start := time.Now()
+		timer := time.NewTimer(progressAfter)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		sent, err := api.Send(tgbotapi.NewMessage(chatID, "⏳ This is taking longer than usual…"))
+		if err != nil {
+			log.Printf("startProgress: error sending progress message: %v", err)
+			<-done
+			return
+		}
+
+		ticker := time.NewTicker(progressEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				h.edits.forget(chatID, sent.MessageID)
+				if _, err := api.Request(tgbotapi.NewDeleteMessage(chatID, sent.MessageID)); err != nil {
+					log.Printf("startProgress: error deleting progress message: %v", err)
+				}
+				return
+			case <-ticker.C:
+				text := fmt.Sprintf("⏳ Still working… (%ds)", int(time.Since(start).Seconds()))
+				h.edits.edit(api, chatID, sent.MessageID, text, nil)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// maxDelayObservations caps the tracker's memory; new departures are ignored past it.
+const maxDelayObservations = 50000
+
+// delayTracker aggregates observed departure delays by line and hour of day.
+// Every departures response a user triggers feeds it; the same departure seen in
+// several responses counts once, with its latest delay. State is in-memory and is
+// reset after each weekly report.
+type delayTracker struct {
+	mu       sync.Mutex
+	loc      *time.Location
+	since    time.Time
+	obs      map[string]delayObservation // journey ID (or line|direction|scheduled) -> observation
+	lastSent string                      // day key of the last weekly report
+}
+
+type delayObservation struct {
+	line  string
+	hour  int
+	delay time.Duration
+}
+
+func newDelayTracker(loc *time.Location) *delayTracker {
+	return &delayTracker{
+		loc:   loc,
+		since: time.Now(),
+		obs:   make(map[string]delayObservation),
+	}
+}
+
+// observe records the delays in a departures response.
+func (t *delayTracker) observe(departures []sl.Departure) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, dep := range departures {
+		if dep.Scheduled.IsZero() || dep.Expected.IsZero() {
+			continue
+		}
+		key := dep.Line + "|" + dep.Direction + "|" + dep.Scheduled.Format(time.RFC3339)
+		if dep.Journey.ID != 0 {
+			key = fmt.Sprintf("journey|%d", dep.Journey.ID)
+		}
+		if _, seen := t.obs[key]; !seen && len(t.obs) >= maxDelayObservations {
+			continue
+		}
+		t.obs[key] = delayObservation{
+			line:  dep.Line,
+			hour:  dep.Scheduled.In(t.loc).Hour(),
+			delay: dep.Expected.Sub(dep.Scheduled),
+		}
+	}
+}
+
+// delayCell is the aggregate for one line and hour.
+type delayCell struct {
+	count int
+	total time.Duration
+}
+
+func (c delayCell) avg() time.Duration {
+	if c.count == 0 {
+		return 0
+	}
+	return c.total / time.Duration(c.count)
+}
+
+// report renders a text heatmap (one row per line, one column per hour) plus
+// the worst line/hour combinations.
+func (t *delayTracker) report() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	grid := make(map[string]*[24]delayCell)
+	for _, o := range t.obs {
+		row, ok := grid[o.line]
+		if !ok {
+			row = &[24]delayCell{}
+			grid[o.line] = row
+		}
+		// Early departures are not delays; count them as on time.
+		delay := o.delay
+		if delay < 0 {
+			delay = 0
+		}
+		row[o.hour].count++
+		row[o.hour].total += delay
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 Delay report since %s (%d departures)\n", t.since.In(t.loc).Format("Mon 2 Jan 15:04"), len(t.obs))
+	if len(grid) == 0 {
+		b.WriteString("No departures observed yet.")
+		return b.String()
+	}
+
+	lines := make([]string, 0, len(grid))
+	for line := range grid {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+
+	b.WriteString("```\nline  0     6     12    18   23\n")
+	for _, line := range lines {
+		fmt.Fprintf(&b, "%-5s ", truncate(line, 5))
+		for h := 0; h < 24; h++ {
+			b.WriteRune(heatGlyph(grid[line][h]))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("```\n· <1m  ▫ 1–3m  ▪ 3–5m  ■ 5m+ (avg delay)\n")
+
+	type worst struct {
+		line string
+		hour int
+		c    delayCell
+	}
+	var all []worst
+	for line, row := range grid {
+		for h, c := range row {
+			if c.count >= 3 { // ignore one-off observations
+				all = append(all, worst{line, h, c})
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].c.avg() > all[j].c.avg() })
+	if len(all) > 0 {
+		b.WriteString("\nWorst:\n")
+		for i, w := range all {
+			if i == 5 || w.c.avg() < time.Minute {
+				break
+			}
+			fmt.Fprintf(&b, "• %s at %02d:00: avg +%dm over %d departures\n", w.line, w.hour, int(w.c.avg().Minutes()), w.c.count)
+		}
+	}
+
+	return b.String()
+}
+
+// reset clears observations after a report.
+func (t *delayTracker) reset(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.obs = make(map[string]delayObservation)
+	t.since = now
+}
+
+func heatGlyph(c delayCell) rune {
+	switch avg := c.avg(); {
+	case c.count == 0:
+		return ' '
+	case avg < time.Minute:
+		return '·'
+	case avg < 3*time.Minute:
+		return '▫'
+	case avg < 5*time.Minute:
+		return '▪'
+	default:
+		return '■'
+	}
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// handleDelayReport sends the current delay heatmap (admin only).
+func (h *Handler) handleDelayReport(api *tgbotapi.BotAPI, chatID int64) {
+	h.sendMessage(api, chatID, h.delays.report())
+}
+
+// RunDelayReport sends the weekly delay report to every admin on Monday mornings
+// and starts a new reporting week. Register it with a scheduler.Scheduler.
+func (h *Handler) RunDelayReport(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	if now.Weekday() != time.Monday || now.Hour() < 8 {
+		return
+	}
+
+	day := scheduler.DayKey(now)
+	h.delays.mu.Lock()
+	alreadySent := h.delays.lastSent == day
+	h.delays.lastSent = day
+	h.delays.mu.Unlock()
+	if alreadySent {
+		return
+	}
+
+	h.notifyAdmins(api, h.delays.report())
+	h.delays.reset(now)
+}
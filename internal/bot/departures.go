@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// A /departures board shows up to boardGroups line/direction rows with
+// boardPerLine departures each.
+const (
+	boardGroups  = 8
+	boardPerLine = 3
+)
+
+// handleDepartures shows the departure board for any stop, grouped by line and
+// direction: "/departures <stop> [<minutes>m]". A trailing "60m" is the
+// forecast window, so rarely running lines show up with a longer one. The unit
+// is required: a bare number belongs to the stop name ("Arlanda Terminal 5").
+func (h *Handler) handleDepartures(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, query string) {
+	query = strings.TrimSpace(query)
+	var window time.Duration
+	if i := strings.LastIndex(query, " "); i > 0 {
+		if w, ok := parseWindow(query[i+1:]); ok {
+			window = w
+			if window < sl.MinForecast || window > sl.MaxForecast {
+				h.sendMessage(api, chatID, fmt.Sprintf("❓ The window must be %d–%d minutes.",
+					int(sl.MinForecast.Minutes()), int(sl.MaxForecast.Minutes())))
+				return
+			}
+			query = strings.TrimSpace(query[:i])
+		}
+	}
+	if query == "" {
+		h.sendMessage(api, chatID, "❓ Usage: /departures <stop> [<minutes>m], e.g. /departures Odenplan 60m")
+		return
+	}
+
+	site, ok := h.resolveSingleSite(ctx, api, chatID, query)
+	if !ok {
+		return
+	}
+
+	var departures []sl.Departure
+	var err error
+	if window > 0 {
+		departures, err = h.slClient.GetDeparturesForecast(ctx, strconv.Itoa(site.SiteID), window)
+	} else {
+		departures, err = h.slClient.GetDepartures(ctx, strconv.Itoa(site.SiteID))
+	}
+	if err != nil {
+		log.Printf("handleDepartures: error fetching departures for %d: %v", site.SiteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+	h.delays.observe(departures)
+
+	groups := sl.GroupByLine(sl.DedupJourneys(departures))
+	if len(groups) == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("No upcoming departures from %s.", site.Name))
+		return
+	}
+
+	th := h.thresholdsFor(userID)
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚏 Departures from %s", site.Name)
+	if window > 0 {
+		fmt.Fprintf(&b, " in the next %d min", int(window.Minutes()))
+	}
+	b.WriteString(":\n\n")
+	for _, g := range groups[:min(boardGroups, len(groups))] {
+		b.WriteString(sl.FormatLineGroup(g, boardPerLine, th) + "\n")
+	}
+	if hidden := len(groups) - boardGroups; hidden > 0 {
+		fmt.Fprintf(&b, "_…and %d more lines_\n", hidden)
+	}
+	h.sendMessage(api, chatID, b.String())
+}
+
+// parseWindow parses a forecast window with its unit, "60m" or "60min".
+func parseWindow(s string) (time.Duration, bool) {
+	digits, ok := strings.CutSuffix(strings.ToLower(s), "min")
+	if !ok {
+		digits, ok = strings.CutSuffix(strings.ToLower(s), "m")
+	}
+	if !ok {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(minutes) * time.Minute, true
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// maxDeviationPreview is how much of a deviation is shown inline on a board.
+const maxDeviationPreview = 80
+
+// deviationLines renders the most important deviation of dep that isn't already
+// in seen, truncated for the board. hidden reports whether anything was left
+// out (a cut message or further deviations), so the board can link to /deviations.
+func deviationLines(dep sl.Departure, seen map[string]bool) (lines string, hidden bool) {
+	devs := append([]sl.Deviation(nil), dep.Deviations...)
+	sort.SliceStable(devs, func(i, j int) bool { return devs[i].ImportanceLevel > devs[j].ImportanceLevel })
+
+	for _, dev := range devs {
+		if dev.Message == "" || seen[dev.Message] {
+			continue
+		}
+		if lines != "" {
+			return lines, true
+		}
+		seen[dev.Message] = true
+		preview, cut := dev.Truncate(maxDeviationPreview)
+		lines = "   ⚠️ " + escapeMarkdown(preview) + "\n"
+		hidden = cut
+	}
+	return lines, hidden
+}
+
+// handleDeviations lists every deviation on the departures from a stop, with
+// the lines each one affects. arg is a site ID when byID is set (from the
+// tappable /deviations_<id> link on boards), otherwise a stop name.
+func (h *Handler) handleDeviations(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, arg string, byID bool) {
+	siteID := strings.TrimSpace(arg)
+	name := siteID
+	if byID {
+		if _, err := strconv.Atoi(siteID); err != nil {
+			h.sendMessage(api, chatID, "❓ Usage: /deviations <stop>")
+			return
+		}
+		name = h.siteNameByID(ctx, siteID)
+	} else {
+		site, ok := h.resolveSingleSite(ctx, api, chatID, siteID)
+		if !ok {
+			return
+		}
+		siteID, name = strconv.Itoa(site.SiteID), site.Name
+	}
+
+	departures, err := h.slClient.GetDepartures(ctx, siteID)
+	if err != nil {
+		log.Printf("handleDeviations: site=%s: %v", siteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+
+	// Group identical notices and remember which lines they were attached to.
+	type notice struct {
+		dev   sl.Deviation
+		lines []string
+	}
+	var notices []*notice
+	byMessage := make(map[string]*notice)
+	for _, dep := range departures {
+		for _, dev := range dep.Deviations {
+			if dev.Message == "" {
+				continue
+			}
+			n, ok := byMessage[dev.Message]
+			if !ok {
+				n = &notice{dev: dev}
+				byMessage[dev.Message] = n
+				notices = append(notices, n)
+			}
+			if !slices.Contains(n.lines, dep.Line) {
+				n.lines = append(n.lines, dep.Line)
+			}
+		}
+	}
+
+	if len(notices) == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("✅ No disruption notices for %s.", name))
+		return
+	}
+	sort.SliceStable(notices, func(i, j int) bool { return notices[i].dev.ImportanceLevel > notices[j].dev.ImportanceLevel })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ Disruption notices for %s:\n", name)
+	for _, n := range notices {
+		fmt.Fprintf(&b, "\n• Line %s: %s\n", strings.Join(n.lines, ", "), escapeMarkdown(n.dev.Message))
+	}
+	h.sendMessage(api, chatID, b.String())
+}
+
+// escapeMarkdown escapes the characters legacy Telegram Markdown treats as markup,
+// for upstream text that is embedded in our Markdown messages.
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(s)
+}
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// digestCatchUp is how long after its time a digest is still sent, e.g. when
+// the bot was restarting at 07:30. Later than that the day is skipped.
+const digestCatchUp = time.Hour
+
+// handleDigest shows, sets or turns off the daily commute digest:
+// "/digest", "/digest 07:30 [daily|weekdays|weekends]" or "/digest off".
+func (h *Handler) handleDigest(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	usage := "❓ Usage: /digest <HH:MM> [daily|weekdays|weekends], or /digest off"
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		d := h.userStore.GetPrefs(userID).Digest
+		if d == nil {
+			h.sendMessage(api, chatID, "No daily digest. Get one with /digest 07:30 weekdays: your board to work and any disruptions at your stops.")
+			return
+		}
+		h.sendMessage(api, chatID, fmt.Sprintf("🌅 Daily digest at %s, %s. Change it with /digest <HH:MM>, or /digest off.",
+			scheduler.FormatClock(d.At), d.Days))
+		return
+	}
+
+	if len(fields) == 1 && fields[0] == "off" {
+		if err := h.userStore.SetDigest(userID, nil); err != nil {
+			log.Printf("handleDigest: error clearing digest: %v", err)
+			h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+			return
+		}
+		h.sendMessage(api, chatID, "🔕 Daily digest off.")
+		return
+	}
+
+	days := scheduler.Weekdays
+	if len(fields) == 2 {
+		d, err := scheduler.ParseDays(fields[1])
+		if err != nil {
+			h.sendMessage(api, chatID, usage)
+			return
+		}
+		days = d
+	}
+	at, err := scheduler.ParseClock(fields[0])
+	if err != nil || len(fields) > 2 {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	if err := h.userStore.SetDigest(userID, &store.Digest{ChatID: chatID, At: at, Days: string(days)}); err != nil {
+		log.Printf("handleDigest: error saving digest: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	log.Printf("handleDigest: user=%d at=%s %s", userID, scheduler.FormatClock(at), days)
+	h.sendMessage(api, chatID, fmt.Sprintf("🌅 Daily digest set for %s, %s (Stockholm time).", scheduler.FormatClock(at), days))
+}
+
+// RunDigests sends daily commute digests that are due. A digest goes out
+// once per day, at its time or up to digestCatchUp after. Register it with
+// a scheduler.Scheduler:
+//
+//	sched.Add("digests", func(ctx context.Context, now time.Time) {
+//		handler.RunDigests(ctx, api, now)
+//	})
+func (h *Handler) RunDigests(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	// Digest times are Stockholm wall-clock times, whatever zone now is in.
+	now = now.In(scheduler.Stockholm())
+	day := scheduler.DayKey(now)
+	minute := scheduler.MinuteOfDay(now)
+
+	for userID, d := range h.userStore.AllDigests() {
+		if h.checkAccess(RequestInfo{UserID: userID, Admin: h.isAdmin(userID)}) != accessAllowed {
+			continue
+		}
+		prefs := h.userStore.GetPrefs(userID)
+		// The digest is a briefing; muting briefings pauses it too.
+		if !prefs.Notifications.Enabled(store.NotifyBriefings) {
+			continue
+		}
+		if d.LastSent == day || !scheduler.Days(d.Days).Includes(now) {
+			continue
+		}
+		if minute < d.At || minute >= d.At+int(digestCatchUp.Minutes()) {
+			continue
+		}
+
+		ctx := WithRequestInfo(ctx, RequestInfo{UserID: userID, ChatID: d.ChatID})
+		message, err := h.digestMessage(ctx, d.ChatID, prefs, now)
+		if err != nil {
+			// Leave LastSent alone so the next tick retries.
+			log.Printf("RunDigests: user=%d: %v", userID, err)
+			continue
+		}
+		h.sendPush(api, userID, d.ChatID, "digest", message)
+
+		if err := h.userStore.MarkDigestSent(userID, day); err != nil {
+			log.Printf("RunDigests: error marking digest sent for user %d: %v", userID, err)
+		}
+	}
+}
+
+// digestMessage renders a digest: the board to work, then the deviations
+// currently reported at the user's home and work stops.
+func (h *Handler) digestMessage(ctx context.Context, chatID int64, prefs store.UserPreferences, now time.Time) (string, error) {
+	workSite, _ := h.savedSiteID(prefs, "work")
+	board, _, err := h.departureBoard(ctx, chatID, workSite, "work")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🌅 Your commute for %s\n\n%s\n", now.Format("Mon 2 Jan"), board)
+
+	seen := make(map[string]bool)
+	var disruptions []string
+	for _, label := range []string{"home", "work"} {
+		siteID, _ := h.savedSiteID(prefs, label)
+		departures, err := h.slClient.GetDepartures(ctx, siteID)
+		if err != nil {
+			log.Printf("digestMessage: site=%s: %v", siteID, err)
+			continue
+		}
+		found := stopDeviations(departures, "")
+		keys := make([]string, 0, len(found))
+		for key := range found {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			d := found[key]
+			preview, _ := sl.Deviation{Message: d.message}.Truncate(maxDeviationPreview)
+			disruptions = append(disruptions, fmt.Sprintf("⚠️ %s: %s", strings.Join(d.lines, ", "), escapeMarkdown(preview)))
+		}
+	}
+
+	if len(disruptions) == 0 {
+		b.WriteString("✅ No disruptions reported at your stops.\n")
+	} else {
+		b.WriteString("Disruptions at your stops:\n" + strings.Join(disruptions, "\n") + "\n")
+	}
+	b.WriteString("\n_Change the time with /digest <HH:MM>, or /digest off._")
+	return b.String(), nil
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleDirection saves which direction matters at the user's home or work stop:
+// "/direction home" lists the directions with their codes, "/direction home 1"
+// keeps only direction 1, and "/direction home all" shows both again.
+func (h *Handler) handleDirection(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	usage := "❓ Usage: /direction home|work [1|2|all]"
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	prefs := h.userStore.GetPrefs(userID)
+	siteID, ok := h.savedSiteID(prefs, fields[0])
+	if !ok {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	if len(fields) == 1 {
+		h.listDirections(ctx, api, chatID, siteID, fields[0], prefs.Directions[siteID])
+		return
+	}
+
+	code := 0
+	if fields[1] != "all" {
+		var err error
+		code, err = strconv.Atoi(fields[1])
+		if err != nil || (code != 1 && code != 2) {
+			h.sendMessage(api, chatID, usage)
+			return
+		}
+	}
+
+	if err := h.userStore.SetDirection(userID, siteID, code); err != nil {
+		log.Printf("handleDirection: error saving direction: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	if code == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("✅ Showing both directions at %s.", fields[0]))
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ At %s you'll only see direction %d.", fields[0], code))
+}
+
+// listDirections shows the destinations served in each direction at siteID,
+// so the user can tell which code means "towards the city".
+func (h *Handler) listDirections(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, siteID, label string, current int) {
+	departures, err := h.slClient.GetDepartures(ctx, siteID)
+	if err != nil {
+		log.Printf("listDirections: site=%s: %v", siteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+
+	destinations := make(map[int][]string)
+	for _, dep := range departures {
+		if dep.DirectionCode == 0 {
+			continue
+		}
+		name := dep.Line + " " + dep.Direction
+		if !slices.Contains(destinations[dep.DirectionCode], name) {
+			destinations[dep.DirectionCode] = append(destinations[dep.DirectionCode], name)
+		}
+	}
+	if len(destinations) == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("SL doesn't give direction codes for %s right now, so it can't be filtered.", label))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Directions at %s:\n", label)
+	for _, code := range []int{1, 2} {
+		if names := destinations[code]; len(names) > 0 {
+			sort.Strings(names)
+			marker := ""
+			if code == current {
+				marker = " ✅"
+			}
+			fmt.Fprintf(&b, "%d: %s%s\n", code, strings.Join(names, ", "), marker)
+		}
+	}
+	fmt.Fprintf(&b, "\nPick one with /direction %s 1 or 2, or /direction %s all", label, label)
+	h.sendMessage(api, chatID, b.String())
+}
@@ -0,0 +1,175 @@
+package bot_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/bot"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/telegramfake"
+)
+
+const e2eUserID = 4242
+
+// e2eStep sends one update and expects one call back from the bot. press, when
+// set, makes the update a button press on the message from step pressOn; job,
+// when set, runs a scheduler job instead of sending an update.
+type e2eStep struct {
+	name     string
+	text     string // message to send; ignored when press or job is set
+	press    string // callback data
+	pressOn  int    // index of the earlier step whose message is pressed
+	job      func(ctx context.Context, h *bot.Handler, api *tgbotapi.BotAPI, slc *sl.Client)
+	method   string // expected Bot API method
+	contains []string
+}
+
+var e2eScenario = []e2eStep{
+	{name: "help", text: "/help", method: "sendMessage", contains: []string{"Available commands"}},
+	{name: "work board", text: "to work", method: "sendMessage",
+		contains: []string{"Next buses to work", `\"a\":\"refresh\"`, `\"a\":\"swap\"`}},
+	{name: "refresh", press: `{"v":1,"a":"refresh"}`, pressOn: 1, method: "editMessageText",
+		contains: []string{"Next buses to work", "Updated"}},
+	{name: "swap", press: `{"v":1,"a":"swap"}`, pressOn: 1, method: "editMessageText",
+		contains: []string{"Next buses to home"}},
+	{name: "departures window", text: "/departures Storgatan 60m", method: "sendMessage",
+		contains: []string{"Departures from Storgatan in the next 60 min"}},
+	{name: "lines layout", text: "/layout lines", method: "sendMessage", contains: []string{"lines layout"}},
+	{name: "grouped board", text: "to home", method: "sendMessage", contains: []string{"Next buses to home", " → "}},
+	// No journey from work reaches home in the fixtures, so the board says so.
+	{name: "from board", text: "from Frösunda to home", method: "sendMessage",
+		contains: []string{"Next buses to home from Frösunda torg", "No direct buses"}},
+	{name: "alert", text: "/subscribe line 4", method: "sendMessage", contains: []string{"line 4"}},
+	// Only the deviation that appears after subscribing is pushed; if the one
+	// already showing were too, the digest step would get its push instead.
+	{name: "alert push", method: "sendMessage", contains: []string{"Disruption on 4", "Lift out of order"},
+		job: func(ctx context.Context, h *bot.Handler, api *tgbotapi.BotAPI, slc *sl.Client) {
+			slc.SetFixtures(liveFixtures(time.Now(), "Signal failure, expect delays.", "Lift out of order."))
+			h.RunDeviationAlerts(ctx, api, time.Now())
+		}},
+	{name: "digest", text: "/digest 07:30", method: "sendMessage", contains: []string{"07:30, weekdays"}},
+	{name: "status", text: "/status", method: "sendMessage", contains: []string{"1 disruption alert", "Daily digest at 07:30"}},
+	{name: "leave", text: "/leave 26", method: "sendMessage", contains: []string{"I'll remind you", "26 → "}},
+	{name: "leave list", text: "/leave", method: "sendMessage", contains: []string{"Your departure alarms"}},
+	// The 26 is moved six minutes earlier, so the alarm armed for 8 minutes from
+	// now is due 3 minutes from now, and is dropped once sent.
+	{name: "leave earlier", method: "sendMessage", contains: []string{"Time to go", "26 → Gullmarsplan"},
+		job: func(ctx context.Context, h *bot.Handler, api *tgbotapi.BotAPI, slc *sl.Client) {
+			slc.SetFixtures(liveFixtures(time.Now().Add(-6*time.Minute), "Signal failure, expect delays.", "Lift out of order."))
+			h.RunAlarms(ctx, api, time.Now().Add(3*time.Minute))
+		}},
+	{name: "leave off", text: "/leave off", method: "sendMessage", contains: []string{"No departure alarms to cancel"}},
+	{name: "unknown", text: "/nonsense", method: "sendMessage"},
+}
+
+// TestEndToEnd runs the bot against a fake Telegram server, feeding updates
+// through the same getUpdates loop it uses in production, and checks what it
+// sends back. Everything is offline: SL is in dry-run mode with fixtures
+// generated relative to now.
+func TestEndToEnd(t *testing.T) {
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+		t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	}
+
+	fake := telegramfake.NewServer()
+	t.Cleanup(fake.Close)
+	api, err := fake.API()
+	if err != nil {
+		t.Fatalf("create bot api: %v", err)
+	}
+
+	slClient := sl.NewClient(http.DefaultClient, true)
+	slClient.SetFixtures(liveFixtures(time.Now(), "Signal failure, expect delays."))
+	handler := bot.NewHandler(slClient, "3484", "3455", store.NewUserStore(filepath.Join(t.TempDir(), "prefs.json")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 1
+	updates := api.GetUpdatesChan(u)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for update := range updates {
+			handler.HandleUpdate(ctx, api, update)
+		}
+	}()
+	// Let the loop finish before the temp dir with the prefs file is removed.
+	t.Cleanup(func() {
+		api.StopReceivingUpdates()
+		<-done
+	})
+
+	replies := make([]telegramfake.Call, len(e2eScenario))
+	for i, st := range e2eScenario {
+		switch {
+		case st.job != nil:
+			st.job(ctx, handler, api, slClient)
+		case st.press != "":
+			fake.PushUpdate(telegramfake.CallbackUpdate(e2eUserID, replies[st.pressOn].MessageID, st.press))
+		default:
+			fake.PushUpdate(telegramfake.TextUpdate(e2eUserID, st.text))
+		}
+
+		call, err := fake.Next(st.method, 3*time.Second)
+		replies[i] = call
+		if err != nil {
+			t.Errorf("%s: %v", st.name, err)
+			continue
+		}
+		got := call.Params.Get("text") + "\n" + call.Params.Get("reply_markup")
+		for _, want := range st.contains {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %s is missing %q in:\n%s", st.name, call.Method, want, got)
+			}
+		}
+	}
+}
+
+// liveFixtures returns dry-run SL responses with departures from now on, so
+// anything comparing departures with the clock (alarms, /leave) has something
+// to work with: line 26 from work (3455) and line 4, with the given
+// deviations, from home (3484).
+func liveFixtures(now time.Time, deviations ...string) fstest.MapFS {
+	departures := func(siteID int, stop, line, direction string, devs []sl.Deviation) []byte {
+		var resp sl.DeparturesResponse
+		for i := 1; i <= 3; i++ {
+			at := now.Add(time.Duration(10*i) * time.Minute).Truncate(time.Minute)
+			resp.Departures = append(resp.Departures, sl.Departure{
+				Scheduled: at, Expected: at, Line: line, Direction: direction,
+				StopArea:   sl.StopArea{Name: stop, SiteID: siteID},
+				Deviations: devs,
+				Journey:    sl.Journey{ID: int64(siteID*100 + i), State: "NORMALPROGRESS"},
+			})
+		}
+		data, _ := json.Marshal(resp)
+		return data
+	}
+	var devs []sl.Deviation
+	for _, message := range deviations {
+		devs = append(devs, sl.Deviation{Message: message})
+	}
+	sites, _ := json.Marshal(sl.SitesResponse{Sites: []sl.Site{
+		{Name: "Storgatan", SiteID: 3484, Type: "STOP_AREA"},
+		{Name: "Frösunda torg", SiteID: 3455, Type: "STOP_AREA"},
+	}})
+
+	return fstest.MapFS{
+		"3455.json":  {Data: departures(3455, "Frösunda torg", "26", "Gullmarsplan", nil)},
+		"3484.json":  {Data: departures(3484, "Storgatan", "4", "Radiohuset", devs)},
+		"sites.json": {Data: sites},
+	}
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// editInterval is the minimum gap between edits in one chat. Telegram starts
+// returning 429s well before one edit per second per chat is exceeded for long.
+const editInterval = time.Second
+
+// shownTTL is how long the last text of an edited message is remembered for
+// skipping no-op edits.
+const shownTTL = time.Hour
+
+type editKey struct {
+	chatID    int64
+	messageID int
+}
+
+type pendingEdit struct {
+	text   string
+	markup *tgbotapi.InlineKeyboardMarkup
+}
+
+type shownText struct {
+	text string
+	at   time.Time
+}
+
+// editCoalescer throttles message edits for live-updating messages. Edits in
+// one chat are spaced by at least interval; rapid successive edits to the same
+// message collapse into the latest one; and an edit that wouldn't change the
+// text is skipped, since Telegram rejects it with a 400.
+type editCoalescer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	nextSlot map[int64]time.Time // chat -> earliest time the next edit may go out
+	pending  map[editKey]pendingEdit
+	shown    map[editKey]shownText // text last sent per message
+}
+
+func newEditCoalescer(interval time.Duration) *editCoalescer {
+	return &editCoalescer{
+		interval: interval,
+		nextSlot: make(map[int64]time.Time),
+		pending:  make(map[editKey]pendingEdit),
+		shown:    make(map[editKey]shownText),
+	}
+}
+
+// edit schedules messageID in chatID to show text (and markup, if not nil;
+// a nil markup removes any inline keyboard). It returns immediately.
+// No-op detection compares the text only.
+func (e *editCoalescer) edit(api *tgbotapi.BotAPI, chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	key := editKey{chatID, messageID}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, queued := e.pending[key]; queued {
+		// A flush is already scheduled; it will send the latest text.
+		e.pending[key] = pendingEdit{text, markup}
+		return
+	}
+	if e.shown[key].text == text {
+		return
+	}
+	e.pending[key] = pendingEdit{text, markup}
+
+	// Reserve the chat's next slot so edits to different messages are spaced too.
+	now := time.Now()
+	slot := e.nextSlot[chatID]
+	if slot.Before(now) {
+		slot = now
+	}
+	e.nextSlot[chatID] = slot.Add(e.interval)
+	time.AfterFunc(slot.Sub(now), func() { e.flush(api, key) })
+}
+
+// forget drops any pending edit for a message, e.g. before deleting it.
+func (e *editCoalescer) forget(chatID int64, messageID int) {
+	key := editKey{chatID, messageID}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.pending, key)
+	delete(e.shown, key)
+}
+
+// flush sends the latest pending edit for key.
+func (e *editCoalescer) flush(api *tgbotapi.BotAPI, key editKey) {
+	e.mu.Lock()
+	p, ok := e.pending[key]
+	delete(e.pending, key)
+	if !ok || e.shown[key].text == p.text {
+		e.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	e.shown[key] = shownText{p.text, now}
+	e.pruneLocked(now)
+	e.mu.Unlock()
+
+	edit := tgbotapi.NewEditMessageText(key.chatID, key.messageID, p.text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = p.markup
+	if _, err := api.Send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		log.Printf("editCoalescer: error editing message %d in chat %d: %v", key.messageID, key.chatID, err)
+	}
+}
+
+// pruneLocked drops bookkeeping for chats and messages that have gone quiet.
+func (e *editCoalescer) pruneLocked(now time.Time) {
+	for chatID, slot := range e.nextSlot {
+		if slot.Before(now) {
+			delete(e.nextSlot, chatID)
+		}
+	}
+	for key, s := range e.shown {
+		if now.Sub(s.at) > shownTTL {
+			delete(e.shown, key)
+		}
+	}
+}
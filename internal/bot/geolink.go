@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mahmad/slbot/internal/httpx"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// Google Maps share links from phones are short links that redirect to the
+// full map URL. Only these hosts are followed, and only maxShortLinkHops times.
+var shortLinkHosts = map[string]bool{"maps.app.goo.gl": true, "goo.gl": true}
+
+const maxShortLinkHops = 3
+
+// shortLinkClient resolves short links one redirect at a time, so each hop's
+// host can be checked before it is requested.
+var shortLinkClient = &http.Client{
+	Transport: httpx.Chain(nil, httpx.Logging()),
+	Timeout:   5 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+var (
+	// "!3d59.33!4d18.06" is the dropped pin in a place URL; prefer it to the viewport.
+	mapsPinRe = regexp.MustCompile(`!3d(-?\d+(?:\.\d+)?)!4d(-?\d+(?:\.\d+)?)`)
+	// "@59.33,18.06,15z" is the map's centre.
+	mapsCentreRe = regexp.MustCompile(`@(-?\d+(?:\.\d+)?),(-?\d+(?:\.\d+)?)`)
+	// "59.33,18.06", as in geo: URIs, ?q= parameters or typed coordinates.
+	latLonRe = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)`)
+)
+
+// geoLinkSites returns the stops closest to the position in a pasted geo: URI,
+// Google Maps link or "lat,lon" pair. isGeo is false when query is none of
+// those and should be matched by name instead.
+func (h *Handler) geoLinkSites(ctx context.Context, query string) (sites []sl.Site, isGeo bool, err error) {
+	query = strings.TrimSpace(query)
+	if u, perr := url.Parse(query); perr == nil && u.Scheme == "https" && shortLinkHosts[u.Host] {
+		if query, err = resolveShortLink(ctx, query); err != nil {
+			return nil, true, err
+		}
+	}
+
+	lat, lon, ok := parseGeoLink(query)
+	if !ok {
+		if strings.HasPrefix(query, "geo:") || strings.Contains(query, "google.") {
+			return nil, true, fmt.Errorf("no coordinates in %q", query)
+		}
+		return nil, false, nil
+	}
+	for _, stop := range sl.Nearest(h.sitesIndex().Sites(), lat, lon, nearbyStops, nearbyRadius) {
+		sites = append(sites, stop.Site)
+	}
+	return sites, true, nil
+}
+
+// parseGeoLink extracts a position from a geo: URI ("geo:59.33,18.06",
+// "geo:0,0?q=59.33,18.06"), a Google Maps URL or a bare "lat,lon" pair.
+func parseGeoLink(link string) (lat, lon float64, ok bool) {
+	if rest, found := strings.CutPrefix(link, "geo:"); found {
+		// Android puts the real position in q when the path is 0,0.
+		if _, q, hasQuery := strings.Cut(rest, "?"); hasQuery {
+			if v, err := url.ParseQuery(q); err == nil {
+				if lat, lon, ok = matchLatLon(latLonRe, v.Get("q")); ok {
+					return lat, lon, true
+				}
+			}
+		}
+		if lat, lon, ok = matchLatLon(latLonRe, rest); ok && (lat != 0 || lon != 0) {
+			return lat, lon, true
+		}
+		return 0, 0, false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme == "" {
+		return matchLatLon(latLonRe, link)
+	}
+	if !strings.Contains(u.Host, "google.") {
+		return 0, 0, false
+	}
+	if lat, lon, ok = matchLatLon(mapsPinRe, u.Path); ok {
+		return lat, lon, true
+	}
+	for _, param := range []string{"q", "query", "ll", "center", "destination"} {
+		if lat, lon, ok = matchLatLon(latLonRe, u.Query().Get(param)); ok {
+			return lat, lon, true
+		}
+	}
+	return matchLatLon(mapsCentreRe, u.Path)
+}
+
+// matchLatLon parses the first two groups of re in s as a valid latitude and longitude.
+func matchLatLon(re *regexp.Regexp, s string) (lat, lon float64, ok bool) {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(m[1], 64)
+	lon, err2 := strconv.ParseFloat(m[2], 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// resolveShortLink follows a short link's redirects to the full map URL.
+func resolveShortLink(ctx context.Context, link string) (string, error) {
+	for hop := 0; hop < maxShortLinkHops; hop++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return "", fmt.Errorf("create request: %w", err)
+		}
+		resp, err := shortLinkClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("resolve short link: %w", err)
+		}
+		resp.Body.Close()
+
+		next, err := resp.Location()
+		if err != nil {
+			return "", fmt.Errorf("resolve short link: status %d without a redirect", resp.StatusCode)
+		}
+		if !shortLinkHosts[next.Host] {
+			return next.String(), nil
+		}
+		link = next.String()
+	}
+	return "", fmt.Errorf("resolve short link: more than %d redirects", maxShortLinkHops)
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// SetCollapseVariants controls whether stop variants ("Solna centrum norra") are
+// collapsed under their parent stop in disambiguation buttons (used at startup).
+func (h *Handler) SetCollapseVariants(collapse bool) {
+	h.collapseVariants = collapse
+}
+
+// siteButtons builds the inline keyboard offered when a query matches several sites.
+// kind is the callback action ("home", "work" or "from"). When collapse is set, variants are
+// folded under their parent and a "Show all stops" button expands them again.
+func siteButtons(kind string, userID int64, sites []sl.Site, collapse bool) tgbotapi.InlineKeyboardMarkup {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+
+	if !collapse {
+		for _, site := range sites {
+			button := tgbotapi.NewInlineKeyboardButtonData(
+				site.Name,
+				encodeCallback(kind, userID, site.SiteID),
+			)
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
+		}
+		return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	}
+
+	hidden := 0
+	for _, group := range sl.GroupSites(sites) {
+		label := group.Parent.Name
+		if n := len(group.Children); n > 0 {
+			label = fmt.Sprintf("%s (+%d)", label, n)
+			hidden += n
+		}
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			label,
+			encodeCallback(kind, userID, group.Parent.SiteID),
+		)
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	if hidden > 0 {
+		expand := tgbotapi.NewInlineKeyboardButtonData(
+			"▸ Show all stops",
+			encodeCallback(kind+"all", userID, 0),
+		)
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{expand})
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// expandSiteButtons replaces a collapsed keyboard with one button per pending site.
+func (h *Handler) expandSiteButtons(api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, kind string, userID int64, pending map[int64][]sl.Site) {
+	h.mu.RLock()
+	matches := pending[userID]
+	h.mu.RUnlock()
+
+	if len(matches) == 0 {
+		h.sendMessage(api, callback.Message.Chat.ID, "❌ Site not found in pending selections.")
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID,
+		siteButtons(kind, userID, matches, false))
+	if _, err := api.Send(edit); err != nil {
+		log.Printf("expandSiteButtons: error editing markup: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+const (
+	defaultGuestLinkTTL = 24 * time.Hour
+	maxGuestLinkTTL     = 7 * 24 * time.Hour
+)
+
+// handleGuestLink creates a link that lends the user's home stop to friends:
+// "/guestlink [hours]". Guests who open it get the stop as a saved place named
+// after the user, which expires with the link.
+func (h *Handler) handleGuestLink(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, from *tgbotapi.User, args string) {
+	ttl := defaultGuestLinkTTL
+	if args = strings.TrimSpace(args); args != "" {
+		hours, err := strconv.Atoi(args)
+		if err != nil || hours <= 0 || time.Duration(hours)*time.Hour > maxGuestLinkTTL {
+			h.sendMessage(api, chatID, "❓ Usage: /guestlink [hours], at most 168")
+			return
+		}
+		ttl = time.Duration(hours) * time.Hour
+	}
+
+	homeSite := h.userStore.GetPrefs(from.ID).HomeSiteID
+	siteID, err := strconv.Atoi(homeSite)
+	if err != nil {
+		h.sendMessage(api, chatID, "❓ Set your home stop with /sethome first.")
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	token, err := h.userStore.CreateGuestToken(store.GuestToken{
+		OwnerID:  from.ID,
+		Label:    guestLabel(from),
+		SiteID:   siteID,
+		SiteName: h.siteNameByID(ctx, homeSite),
+		Expires:  expires,
+	})
+	if err != nil {
+		log.Printf("handleGuestLink: error saving token: %v", err)
+		h.sendMessage(api, chatID, "❌ Error creating guest link. Try again later.")
+		return
+	}
+
+	link := fmt.Sprintf("https://t.me/%s?start=g_%s", api.Self.UserName, token)
+	h.sendMessage(api, chatID, fmt.Sprintf("🎟 Share this link; it works until %s:\n%s",
+		expires.In(scheduler.Stockholm()).Format("Mon 2 Jan 15:04"), link))
+}
+
+// handleStart handles "/start <payload>" deep links. Only guest links ("g_<token>") use a payload today.
+func (h *Handler) handleStart(api *tgbotapi.BotAPI, chatID int64, userID int64, payload string) {
+	token, ok := strings.CutPrefix(strings.TrimSpace(payload), "g_")
+	if !ok {
+		h.handleHelp(api, chatID)
+		return
+	}
+
+	guest, err := h.userStore.RedeemGuestToken(token, userID)
+	if err != nil {
+		log.Printf("handleStart: user=%d: %v", userID, err)
+		h.sendMessage(api, chatID, "❌ This guest link is invalid or has expired.")
+		return
+	}
+
+	log.Printf("handleStart: user=%d redeemed guest link from user=%d", userID, guest.OwnerID)
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ Added '%s' to your places until %s. Try: from <your stop> to %s",
+		guest.Label, guest.Expires.In(scheduler.Stockholm()).Format("Mon 2 Jan 15:04"), guest.Label))
+}
+
+// guestLabel turns the owner's first name into a place alias ("Anna Maria" -> "annamaria").
+func guestLabel(from *tgbotapi.User) string {
+	label := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, from.FirstName)
+	if label == "" || label == "home" || label == "work" {
+		return "host"
+	}
+	return label
+}
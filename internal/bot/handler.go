@@ -4,69 +4,218 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/logging"
+	"github.com/mahmad/slbot/internal/scheduler"
 	"github.com/mahmad/slbot/internal/sl"
 	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/webhook"
 )
 
+// callbackTTL is how long processed callback IDs are remembered for deduplication.
+const callbackTTL = 15 * time.Minute
+
 // Handler processes Telegram messages and coordinates bot logic.
 // It receives the SL client and site IDs via dependency injection.
 type Handler struct {
 	slClient   *sl.Client
 	homeSiteID string
 	workSiteID string
-	userStore  *store.UserStore
-	sites      []sl.Site // cached sites list
+	userStore  store.Store
+	siteIndex  *sl.SiteIndex // cached sites list and its search index; replaced on refresh, guarded by mu
 
 	// For button callbacks: store pending site selections
 	// Maps userID to available sites for home/work selection
-	pendingHome map[int64][]sl.Site
-	pendingWork map[int64][]sl.Site
-	mu          sync.RWMutex // protect concurrent map access
+	pendingHome     map[int64][]sl.Site
+	pendingWork     map[int64][]sl.Site
+	pendingFrom     map[int64][]sl.Site // one-off origin overrides awaiting a choice
+	pendingFromDest map[int64]string    // destination label for pendingFrom
+	alarms          map[string]*alarm   // "userID|departureKey" -> departure alarm, see RunAlarms
+	saveAlertSince  time.Time           // when the save failure admins were told about began; zero if none, see RunSaveRetry
+	admins          map[int64]bool      // user IDs allowed to run admin commands
+	allowlist       map[int64]bool      // if non-empty, only these users (and admins) are served
+	blocklist       map[int64]bool      // users never served, see SetAccessLists
+	staleChats      map[int64]string    // chat -> stop shown stale during an SL outage, see RunOutageRecovery
+	mu              sync.RWMutex        // protect concurrent map access
+
+	delays *delayTracker  // observed departure delays for the admin report
+	canary *canary        // trial of a new board layout, see SetFormatterCanary
+	edits  *editCoalescer // throttled edits of live messages
+
+	// Startup settings, set via the Set* methods before handling updates.
+	collapseVariants bool                // fold stop variants under their parent in site buttons
+	thresholds       format.Thresholds   // default early/late labelling, users may override late
+	webhooks         *webhook.Dispatcher // outbound event webhooks, nil if disabled
+
+	callbacks map[string]callbackHandler // inline button action -> handler, see registerCallbacks
+	started   time.Time                  // when the handler was created, for /status
 }
 
 // NewHandler constructs a Handler.
-func NewHandler(slClient *sl.Client, homeSiteID, workSiteID string, userStore *store.UserStore) *Handler {
-	return &Handler{
-		slClient:    slClient,
-		homeSiteID:  homeSiteID,
-		workSiteID:  workSiteID,
-		userStore:   userStore,
-		sites:       []sl.Site{},
-		pendingHome: make(map[int64][]sl.Site),
-		pendingWork: make(map[int64][]sl.Site),
+func NewHandler(slClient *sl.Client, homeSiteID, workSiteID string, userStore store.Store) *Handler {
+	h := &Handler{
+		slClient:        slClient,
+		homeSiteID:      homeSiteID,
+		workSiteID:      workSiteID,
+		userStore:       userStore,
+		siteIndex:       sl.NewSiteIndex(nil),
+		pendingHome:     make(map[int64][]sl.Site),
+		pendingWork:     make(map[int64][]sl.Site),
+		pendingFrom:     make(map[int64][]sl.Site),
+		pendingFromDest: make(map[int64]string),
+		staleChats:      make(map[int64]string),
+		alarms:          make(map[string]*alarm),
+
+		delays:           newDelayTracker(scheduler.Stockholm()),
+		canary:           newCanary(),
+		edits:            newEditCoalescer(editInterval),
+		collapseVariants: true,
+		thresholds:       format.DefaultThresholds,
+		started:          time.Now(),
 	}
+	h.registerCallbacks()
+	return h
 }
 
 // HandleMessage processes a single Telegram message.
 // It examines the message text and dispatches to the appropriate handler.
+// Each command runs under its own deadline (see commandTimeout); a shorter
+// deadline on ctx still wins.
 func (h *Handler) HandleMessage(ctx context.Context, api *tgbotapi.BotAPI, msg *tgbotapi.Message) {
 	// Normalize the message: lowercase and trim whitespace.
 	text := strings.ToLower(strings.TrimSpace(msg.Text))
 
-	log.Printf("User %d: %s", msg.From.ID, text)
+	ctx, info := h.withRequest(ctx, msg.From, msg.Chat.ID)
+
+	switch h.checkAccess(info) {
+	case accessBlocked:
+		log.Printf("User %d: blocked, ignoring message", msg.From.ID)
+		return
+	case accessNotAllowed:
+		log.Printf("User %d: not on allowlist", msg.From.ID)
+		h.sendMessage(api, msg.Chat.ID, "⛔ This bot is private.")
+		return
+	}
+
+	// One line per command, in text mode as well ("INFO command handled user_id=...").
+	// Only the command is logged: arguments can be free text, links or tokens.
+	command, _, _ := strings.Cut(text, " ")
+	defer func(start time.Time) {
+		slog.Info("command handled",
+			logging.KeyUserID, msg.From.ID,
+			logging.KeyCommand, command,
+			logging.KeyLatencyMs, time.Since(start).Milliseconds())
+	}(time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout(text))
+	defer cancel()
+	defer h.startProgress(api, msg.Chat.ID)()
 
 	// Handle different commands.
 	// Commands with arguments are handled via prefix matching.
 	switch {
+	case msg.Location != nil:
+		h.handleLocation(ctx, api, msg.Chat.ID, msg.From.ID, msg.Location)
+	case strings.HasPrefix(text, "/departures "):
+		h.handleDepartures(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/departures "))
+	case text == "/nearby":
+		h.handleNearby(api, msg.Chat.ID)
 	case text == "to work":
 		h.handleToWork(ctx, api, msg.Chat.ID, msg.From.ID)
 	case text == "to home":
 		h.handleToHome(ctx, api, msg.Chat.ID, msg.From.ID)
+	case strings.HasPrefix(text, "from "):
+		h.handleFrom(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "from "))
+	case text == "/start":
+		h.handleHelp(api, msg.Chat.ID)
+	case strings.HasPrefix(text, "/start "):
+		h.handleStart(api, msg.Chat.ID, msg.From.ID, rawArgs(msg.Text, "/start "))
+	case text == "/guestlink" || strings.HasPrefix(text, "/guestlink "):
+		h.handleGuestLink(ctx, api, msg.Chat.ID, msg.From, strings.TrimPrefix(text, "/guestlink"))
+	case text == "/status":
+		h.handleStatus(api, msg.Chat.ID, msg.From.ID)
 	case text == "/help":
 		h.handleHelp(api, msg.Chat.ID)
 	case text == "/prefs":
 		h.handlePrefs(ctx, api, msg.Chat.ID, msg.From.ID)
 	case strings.HasPrefix(text, "/sethome "):
-		query := strings.TrimPrefix(text, "/sethome ")
+		// Raw: short map links are case-sensitive. Stop matching ignores case anyway.
+		query := rawArgs(msg.Text, "/sethome ")
 		h.handleSetHome(ctx, api, msg.Chat.ID, msg.From.ID, query)
 	case strings.HasPrefix(text, "/setwork "):
-		query := strings.TrimPrefix(text, "/setwork ")
+		query := rawArgs(msg.Text, "/setwork ")
 		h.handleSetWork(ctx, api, msg.Chat.ID, msg.From.ID, query)
+	case text == "/quota" && info.Admin:
+		h.handleQuota(api, msg.Chat.ID)
+	case (text == "/limits" || strings.HasPrefix(text, "/limits ")) && info.Admin:
+		h.handleLimits(api, msg.Chat.ID, strings.TrimPrefix(text, "/limits"))
+	case strings.HasPrefix(text, "/ban ") && info.Admin:
+		h.handleBan(api, msg.Chat.ID, strings.TrimPrefix(text, "/ban "), true)
+	case strings.HasPrefix(text, "/unban ") && info.Admin:
+		h.handleBan(api, msg.Chat.ID, strings.TrimPrefix(text, "/unban "), false)
+	case text == "/banned" && info.Admin:
+		h.handleBanned(api, msg.Chat.ID)
+	case (text == "/canary" || strings.HasPrefix(text, "/canary ")) && info.Admin:
+		h.handleCanary(api, msg.Chat.ID, strings.TrimPrefix(text, "/canary"))
+	case strings.HasPrefix(text, "/feedback "):
+		h.handleFeedback(api, msg.Chat.ID, msg.From.ID, rawArgs(msg.Text, "/feedback "))
+	case strings.HasPrefix(text, "/rawdepartures ") && info.Admin:
+		h.handleRawDepartures(ctx, api, msg.Chat.ID, strings.TrimPrefix(text, "/rawdepartures "))
+	case text == "/delayreport" && info.Admin:
+		h.handleDelayReport(api, msg.Chat.ID)
+	case text == "/notifications":
+		h.handleNotifications(api, msg.Chat.ID, msg.From.ID)
+	case text == "/history":
+		h.handleHistory(api, msg.Chat.ID, msg.From.ID)
+	case text == "/subscriptions":
+		h.handleListSubscriptions(api, msg.Chat.ID, msg.From.ID)
+	case strings.HasPrefix(text, "/subscribe line "), text == "/subscribe commute", text == "/subscribe my commute":
+		h.handleSubscribeAlert(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/subscribe "))
+	case strings.HasPrefix(text, "/subscribe "):
+		h.handleSubscribe(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/subscribe "))
+	case strings.HasPrefix(text, "/unsubscribe line "), text == "/unsubscribe commute", text == "/unsubscribe my commute":
+		h.handleUnsubscribeAlert(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/unsubscribe "))
+	case strings.HasPrefix(text, "/unsubscribe "):
+		h.handleUnsubscribe(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/unsubscribe "))
+	case text == "/places":
+		h.handleListPlaces(api, msg.Chat.ID, msg.From.ID)
+	case strings.HasPrefix(text, "/place "):
+		h.handleSetPlace(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/place "))
+	case strings.HasPrefix(text, "/delplace "):
+		h.handleDeletePlace(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/delplace "))
+	case text == "/leave" || strings.HasPrefix(text, "/leave "):
+		h.handleLeave(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/leave"))
+	case strings.HasPrefix(text, "/walk "):
+		h.handleWalk(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/walk "))
+	case text == "/digest" || strings.HasPrefix(text, "/digest "):
+		h.handleDigest(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/digest"))
+	case text == "/layout" || strings.HasPrefix(text, "/layout "):
+		h.handleLayout(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/layout"))
+	case text == "/late" || strings.HasPrefix(text, "/late "):
+		h.handleLate(api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/late"))
+	case strings.HasPrefix(text, "/direction "):
+		h.handleDirection(ctx, api, msg.Chat.ID, msg.From.ID, strings.TrimPrefix(text, "/direction "))
+	case strings.HasPrefix(text, "/webhook "):
+		h.handleWebhook(api, msg.Chat.ID, msg.From.ID, rawArgs(msg.Text, "/webhook "))
+	case text == "/notes":
+		h.handleListNotes(ctx, api, msg.Chat.ID)
+	case strings.HasPrefix(text, "/note "):
+		h.handleAddNote(ctx, api, msg, rawArgs(msg.Text, "/note "))
+	case strings.HasPrefix(text, "/deviations_"):
+		h.handleDeviations(ctx, api, msg.Chat.ID, strings.TrimPrefix(text, "/deviations_"), true)
+	case strings.HasPrefix(text, "/deviations "):
+		h.handleDeviations(ctx, api, msg.Chat.ID, strings.TrimPrefix(text, "/deviations "), false)
+	case strings.HasPrefix(text, "/delnote "):
+		h.handleDeleteNote(api, msg.Chat.ID, strings.TrimPrefix(text, "/delnote "))
+	case strings.HasPrefix(text, "/sharenotes "):
+		h.handleShareNotes(api, msg.Chat.ID, strings.TrimPrefix(text, "/sharenotes "))
 	default:
 		h.handleUnknown(api, msg.Chat.ID)
 	}
@@ -80,16 +229,13 @@ func (h *Handler) handleToWork(ctx context.Context, api *tgbotapi.BotAPI, chatID
 		workSiteID = prefs.WorkSiteID
 	}
 
-	departures, err := h.slClient.GetDepartures(ctx, workSiteID)
+	message, shown, err := h.departureBoard(ctx, chatID, workSiteID, "work")
 	if err != nil {
 		log.Printf("error fetching work departures: %v", err)
 		h.sendMessage(api, chatID, "❌ Error fetching work departures. Try again later.")
 		return
 	}
-
-	formatted := sl.FormatDepartures(departures, 3)
-	message := fmt.Sprintf("🚌 Next buses to work:\n\n%s", formatted)
-	h.sendMessage(api, chatID, message)
+	h.sendBoard(api, chatID, workSiteID, "work", "", message, shown)
 }
 
 // handleToHome fetches departures for the home site and sends them as a Telegram message.
@@ -100,16 +246,71 @@ func (h *Handler) handleToHome(ctx context.Context, api *tgbotapi.BotAPI, chatID
 		homeSiteID = prefs.HomeSiteID
 	}
 
-	departures, err := h.slClient.GetDepartures(ctx, homeSiteID)
+	message, shown, err := h.departureBoard(ctx, chatID, homeSiteID, "home")
 	if err != nil {
 		log.Printf("error fetching home departures: %v", err)
 		h.sendMessage(api, chatID, "❌ Error fetching home departures. Try again later.")
 		return
 	}
+	h.sendBoard(api, chatID, homeSiteID, "home", "", message, shown)
+}
 
-	formatted := sl.FormatDepartures(departures, 3)
-	message := fmt.Sprintf("🚌 Next buses to home:\n\n%s", formatted)
-	h.sendMessage(api, chatID, message)
+// departureBoard fetches departures at siteID and renders the "Next buses to <dest>" message,
+// including any stop notes for the chat. It also returns the departures shown.
+func (h *Handler) departureBoard(ctx context.Context, chatID int64, siteID, dest string) (string, []sl.Departure, error) {
+	return h.departureBoardTowards(ctx, chatID, siteID, dest, "")
+}
+
+// departureBoardTowards is departureBoard limited to the buses that go on to
+// call at the stop towards (see sl.TowardsStop); with towards "" it shows all.
+// If none are known to, or the destination's departures can't be fetched, the
+// board shows all departures and says so.
+func (h *Handler) departureBoardTowards(ctx context.Context, chatID int64, siteID, dest, towards string) (string, []sl.Departure, error) {
+	return h.renderBoard(ctx, chatID, siteID, fmt.Sprintf("🚌 Next buses to %s:", dest), towards)
+}
+
+// renderBoard is departureBoardTowards under any heading, e.g. one naming the
+// stop rather than where the buses go.
+func (h *Handler) renderBoard(ctx context.Context, chatID int64, siteID, heading, towards string) (string, []sl.Departure, error) {
+	departures, err := h.slClient.GetDepartures(ctx, siteID)
+	staleNote := ""
+	if err != nil {
+		// When SL is down altogether, older departures beat an error.
+		stale, note, ok := h.staleDepartures(chatID, siteID, time.Now())
+		if !ok {
+			return "", nil, err
+		}
+		log.Printf("departureBoard: serving stale departures for site %s: %v", siteID, err)
+		departures, staleNote = stale, note
+	} else {
+		h.delays.observe(departures)
+	}
+	departures = sl.DedupJourneys(departures)
+
+	filterNote := ""
+	if info, ok := RequestInfoFrom(ctx); ok {
+		if code := h.userStore.GetPrefs(info.UserID).Directions[siteID]; code != 0 {
+			departures = sl.FilterDepartures(departures, "", code)
+			filterNote = "_Showing your saved direction only; /direction changes it._\n"
+		}
+	}
+	if towards != "" {
+		if kept := h.towardsStop(ctx, departures, towards); kept != nil {
+			departures = kept
+		} else {
+			filterNote += "_No direct buses to your destination found; showing all departures._\n"
+		}
+	}
+
+	formatted := h.formatBoard(ctx, chatID, siteID, departures, 3)
+	message := staleNote + heading + "\n\n" + formatted
+	for _, hw := range sl.LineHeadways(departures) {
+		message += fmt.Sprintf("🔁 %s → %s: %s\n", hw.Line, hw.Direction, sl.FormatHeadway(hw))
+	}
+	shown := departures[:min(3, len(departures))]
+	message += h.stopTips(chatID, siteID, shown)
+	message += filterNote
+	return message, shown, nil
 }
 
 // handleHelp sends the help message listing all available commands.
@@ -117,9 +318,36 @@ func (h *Handler) handleHelp(api *tgbotapi.BotAPI, chatID int64) {
 	help := `Available commands:
 • to work - Next buses to work
 • to home - Next buses to home
-• /sethome <location> - Set your home bus stop
-• /setwork <location> - Set your work bus stop
+• from <place> to <place> - Buses from another stop or saved place that go to the other
+• /departures <stop> [<minutes>m] - Next departures from any stop, by line (e.g. 60m)
+• /nearby - Closest stops to a location you share
+• /place <alias> <stop> - Save a place (e.g. /place gym Fridhemsplan)
+• /places - List saved places
+• /delplace <alias> - Delete a saved place
+• /guestlink [hours] - Link that lends your home stop to friends for a while
+• /sethome <location> - Set your home bus stop (a stop name, or a pasted map link)
+• /setwork <location> - Set your work bus stop (a stop name, or a pasted map link)
 • /prefs - Show saved home/work preferences
+• /subscribe <stop> <HH:MM-HH:MM> [weekdays] - Daily departure board for a stop
+• /subscriptions - List your subscriptions
+• /unsubscribe <number> - Remove a subscription
+• /subscribe line <line> - Alerts for new disruptions on a line at your home and work stops
+• /subscribe commute - Same, for every line there (/unsubscribe line <line> or commute stops them)
+• /digest <HH:MM> [weekdays] - Morning message with your board to work and disruptions
+• /notifications - Switch briefings and alerts on or off
+• /history - Briefings and alerts sent to you recently
+• /status - Is SL reachable, and what the bot is watching for you
+• /direction home|work [1|2|all] - Only show one direction at that stop
+• /walk home|work <minutes> - Walking time to the stop, for ⏰ departure alarms
+• /leave <line> [home|work] - Tell me when to set off for the next one (/leave off cancels)
+• /layout list|lines - One row per departure, or per line with its next times
+• /late <minutes>|default - How late a bus must be before it's shown as late
+• /webhook <https-url>|off - POST your briefings and alerts to a URL
+• /deviations <stop> - Full disruption notices for a stop
+• /note <stop> | [line |] <tip> - Attach a tip to a stop for this chat
+• /notes - List this chat's stop notes
+• /delnote <number> - Delete a stop note
+• /sharenotes on|off - Show or hide stop notes in a group
 • /help - Show this message`
 	h.sendMessage(api, chatID, help)
 }
@@ -131,32 +359,45 @@ func (h *Handler) handleSetHome(ctx context.Context, api *tgbotapi.BotAPI, chatI
 		return
 	}
 
-	log.Printf("handleSetHome: user=%d query=%q cached_sites=%d", userID, query, len(h.sites))
+	log.Printf("handleSetHome: user=%d query=%q cached_sites=%d", userID, query, h.sitesIndex().Len())
 
 	// Load sites if not already cached.
-	if len(h.sites) == 0 {
+	if h.sitesIndex().Len() == 0 {
 		sites, err := h.slClient.GetSites(ctx)
 		if err != nil {
 			log.Printf("handleSetHome: error fetching sites: %v", err)
 			h.sendMessage(api, chatID, "❌ Error fetching sites. Try again later.")
 			return
 		}
-		h.sites = sites
-		log.Printf("handleSetHome: fetched %d sites", len(h.sites))
+		h.setSites(sites)
+		log.Printf("handleSetHome: fetched %d sites", len(sites))
 		// Log site list (limit to first 200 entries)
-		max := len(h.sites)
+		max := len(sites)
 		if max > 200 {
 			max = 200
 		}
 		for i := 0; i < max; i++ {
-			s := h.sites[i]
+			s := sites[i]
 			log.Printf("handleSetHome: site %d: %s (id=%d)", i, s.Name, s.SiteID)
 		}
 	}
 
-	// Fuzzy match the query.
-	matches := sl.FuzzyMatch(query, h.sites, 3)
-	log.Printf("handleSetHome: matches=%d", len(matches))
+	// A pasted map link or geo: URI picks the stops closest to it instead.
+	matches, isGeo, err := h.geoLinkSites(ctx, query)
+	if err != nil {
+		log.Printf("handleSetHome: %v", err)
+		h.sendMessage(api, chatID, "❌ Couldn't read a location from that link. Share it again, or type the stop name.")
+		return
+	}
+	if !isGeo {
+		// Fuzzy match the query.
+		matches = h.sitesIndex().Match(query, 3)
+	}
+	log.Printf("handleSetHome: matches=%d geo=%t", len(matches), isGeo)
+	if len(matches) == 0 && isGeo {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No stops within %s of that location.", format.Distance(nearbyRadius)))
+		return
+	}
 	if len(matches) == 0 {
 		h.sendMessage(api, chatID, fmt.Sprintf("❌ No sites found matching '%s'", query))
 		return
@@ -186,17 +427,12 @@ func (h *Handler) handleSetHome(ctx context.Context, api *tgbotapi.BotAPI, chatI
 	h.mu.Unlock()
 
 	// Create inline buttons for each match
-	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, site := range matches {
-		button := tgbotapi.NewInlineKeyboardButtonData(
-			site.Name,
-			fmt.Sprintf("home_%d_%d", userID, site.SiteID),
-		)
-		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
+	markup := siteButtons("home", userID, matches, h.collapseVariants)
+	prompt := fmt.Sprintf("Multiple matches for '%s'. Which one?", query)
+	if isGeo {
+		prompt = "Stops near that location, closest first. Which one?"
 	}
-
-	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Multiple matches for '%s'. Which one?", query))
+	msg := tgbotapi.NewMessage(chatID, prompt)
 	msg.ReplyMarkup = markup
 	if _, err := api.Send(msg); err != nil {
 		log.Printf("handleSetHome: error sending button message: %v", err)
@@ -210,32 +446,45 @@ func (h *Handler) handleSetWork(ctx context.Context, api *tgbotapi.BotAPI, chatI
 		return
 	}
 
-	log.Printf("handleSetWork: user=%d query=%q cached_sites=%d", userID, query, len(h.sites))
+	log.Printf("handleSetWork: user=%d query=%q cached_sites=%d", userID, query, h.sitesIndex().Len())
 
 	// Load sites if not already cached.
-	if len(h.sites) == 0 {
+	if h.sitesIndex().Len() == 0 {
 		sites, err := h.slClient.GetSites(ctx)
 		if err != nil {
 			log.Printf("handleSetWork: error fetching sites: %v", err)
 			h.sendMessage(api, chatID, "❌ Error fetching sites. Try again later.")
 			return
 		}
-		h.sites = sites
-		log.Printf("handleSetWork: fetched %d sites", len(h.sites))
+		h.setSites(sites)
+		log.Printf("handleSetWork: fetched %d sites", len(sites))
 		// Log site list (limit to first 200 entries)
-		max := len(h.sites)
+		max := len(sites)
 		if max > 200 {
 			max = 200
 		}
 		for i := 0; i < max; i++ {
-			s := h.sites[i]
+			s := sites[i]
 			log.Printf("handleSetWork: site %d: %s (id=%d)", i, s.Name, s.SiteID)
 		}
 	}
 
-	// Fuzzy match the query.
-	matches := sl.FuzzyMatch(query, h.sites, 3)
-	log.Printf("handleSetWork: matches=%d", len(matches))
+	// A pasted map link or geo: URI picks the stops closest to it instead.
+	matches, isGeo, err := h.geoLinkSites(ctx, query)
+	if err != nil {
+		log.Printf("handleSetWork: %v", err)
+		h.sendMessage(api, chatID, "❌ Couldn't read a location from that link. Share it again, or type the stop name.")
+		return
+	}
+	if !isGeo {
+		// Fuzzy match the query.
+		matches = h.sitesIndex().Match(query, 3)
+	}
+	log.Printf("handleSetWork: matches=%d geo=%t", len(matches), isGeo)
+	if len(matches) == 0 && isGeo {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No stops within %s of that location.", format.Distance(nearbyRadius)))
+		return
+	}
 	if len(matches) == 0 {
 		h.sendMessage(api, chatID, fmt.Sprintf("❌ No sites found matching '%s'", query))
 		return
@@ -264,17 +513,12 @@ func (h *Handler) handleSetWork(ctx context.Context, api *tgbotapi.BotAPI, chatI
 	h.mu.Unlock()
 
 	// Create inline buttons for each match
-	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, site := range matches {
-		button := tgbotapi.NewInlineKeyboardButtonData(
-			site.Name,
-			fmt.Sprintf("work_%d_%d", userID, site.SiteID),
-		)
-		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
+	markup := siteButtons("work", userID, matches, h.collapseVariants)
+	prompt := fmt.Sprintf("Multiple matches for '%s'. Which one?", query)
+	if isGeo {
+		prompt = "Stops near that location, closest first. Which one?"
 	}
-
-	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Multiple matches for '%s'. Which one?", query))
+	msg := tgbotapi.NewMessage(chatID, prompt)
 	msg.ReplyMarkup = markup
 	if _, err := api.Send(msg); err != nil {
 		log.Printf("handleSetWork: error sending button message: %v", err)
@@ -301,8 +545,13 @@ func (h *Handler) handlePrefs(ctx context.Context, api *tgbotapi.BotAPI, chatID
 	}
 	workName := h.siteNameByID(ctx, workSite)
 
-	msg := fmt.Sprintf("Your preferences:\nHome: %s %s (site %s)\nWork: %s %s (site %s)\n\nChange with /sethome <name> and /setwork <name>",
-		homeName, homeNote, homeSite, workName, workNote, workSite)
+	layout := prefs.Layout
+	if layout == "" {
+		layout = store.LayoutList
+	}
+
+	msg := fmt.Sprintf("Your preferences:\nHome: %s %s (site %s)\nWork: %s %s (site %s)\nBoard layout: %s\n\nChange with /sethome <name>, /setwork <name> and /layout",
+		homeName, homeNote, homeSite, workName, workNote, workSite, layout)
 
 	h.sendMessage(api, chatID, msg)
 }
@@ -310,17 +559,17 @@ func (h *Handler) handlePrefs(ctx context.Context, api *tgbotapi.BotAPI, chatID
 // siteNameByID returns a friendly site name for a site ID string.
 // It ensures the handler's site cache is populated.
 func (h *Handler) siteNameByID(ctx context.Context, siteID string) string {
-	if len(h.sites) == 0 {
+	if h.sitesIndex().Len() == 0 {
 		sites, err := h.slClient.GetSites(ctx)
 		if err == nil {
-			h.sites = sites
+			h.setSites(sites)
 		}
 	}
 
 	// siteID is stored as string; SL Site.SiteID is int
 	id, err := strconv.Atoi(siteID)
 	if err == nil {
-		for _, s := range h.sites {
+		for _, s := range h.sitesIndex().Sites() {
 			if s.SiteID == id {
 				return s.Name
 			}
@@ -336,106 +585,46 @@ func (h *Handler) handleUnknown(api *tgbotapi.BotAPI, chatID int64) {
 	h.sendMessage(api, chatID, "❓ Unknown command. Type /help for available commands.")
 }
 
-// HandleCallback processes inline button callbacks (site selection).
-// Expected callback data format: "home_<userID>_<siteID>" or "work_<userID>_<siteID>"
+// HandleCallback processes inline button callbacks.
+// The payload is decoded with decodeCallback and routed by its action to a
+// handler in the registry; see registerCallbacks.
 func (h *Handler) HandleCallback(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
-	data := callback.Data
-	parts := strings.Split(data, "_")
-	if len(parts) != 3 {
-		log.Printf("HandleCallback: invalid data format: %s", data)
+	// Check who pressed the button before anything is stored for it. Presses on
+	// inline-mode messages come without a chat.
+	var chatID int64
+	if callback.Message != nil {
+		chatID = callback.Message.Chat.ID
+	}
+	ctx, info := h.withRequest(ctx, callback.From, chatID)
+	if callback.From == nil || h.checkAccess(info) != accessAllowed {
+		log.Printf("HandleCallback: user %d not allowed, ignoring", info.UserID)
 		return
 	}
 
-	action := parts[0]
-	userID, err := strconv.ParseInt(parts[1], 10, 64)
+	// Telegram can deliver the same callback twice; only act on the first delivery.
+	first, err := h.userStore.MarkCallback(callback.ID, callbackTTL)
 	if err != nil {
-		log.Printf("HandleCallback: invalid userID: %s", parts[1])
-		return
+		log.Printf("HandleCallback: error persisting callback %s: %v", callback.ID, err)
 	}
-	siteID, err := strconv.Atoi(parts[2])
-	if err != nil {
-		log.Printf("HandleCallback: invalid siteID: %s", parts[2])
+	if !first {
+		log.Printf("HandleCallback: ignoring duplicate callback %s", callback.ID)
 		return
 	}
 
-	var siteName string
-
-	if action == "home" {
-		h.mu.RLock()
-		matches := h.pendingHome[userID]
-		h.mu.RUnlock()
-
-		// Find the selected site by ID
-		for _, site := range matches {
-			if site.SiteID == siteID {
-				siteName = site.Name
-				break
-			}
-		}
-
-		if siteName == "" {
-			h.sendMessage(api, callback.Message.Chat.ID, "❌ Site not found in pending selections.")
-			return
-		}
-
-		// Save the preference
-		if err := h.userStore.SetHome(userID, fmt.Sprintf("%d", siteID)); err != nil {
-			log.Printf("HandleCallback: error setting home: %v", err)
-			h.sendMessage(api, callback.Message.Chat.ID, "❌ Error saving preference.")
-			return
-		}
-
-		// Clean up pending
-		h.mu.Lock()
-		delete(h.pendingHome, userID)
-		h.mu.Unlock()
-
-		// Edit the message to show confirmation
-		edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
-			fmt.Sprintf("✅ Home set to: %s", siteName))
-		if _, err := api.Send(edit); err != nil {
-			log.Printf("HandleCallback: error editing message: %v", err)
-		}
-		log.Printf("HandleCallback: saved home site %d (%s) for user %d", siteID, siteName, userID)
-
-	} else if action == "work" {
-		h.mu.RLock()
-		matches := h.pendingWork[userID]
-		h.mu.RUnlock()
-
-		// Find the selected site by ID
-		for _, site := range matches {
-			if site.SiteID == siteID {
-				siteName = site.Name
-				break
-			}
-		}
-
-		if siteName == "" {
-			h.sendMessage(api, callback.Message.Chat.ID, "❌ Site not found in pending selections.")
-			return
-		}
-
-		// Save the preference
-		if err := h.userStore.SetWork(userID, fmt.Sprintf("%d", siteID)); err != nil {
-			log.Printf("HandleCallback: error setting work: %v", err)
-			h.sendMessage(api, callback.Message.Chat.ID, "❌ Error saving preference.")
-			return
-		}
+	ctx, cancel := context.WithTimeout(ctx, callbackCommandTimeout)
+	defer cancel()
 
-		// Clean up pending
-		h.mu.Lock()
-		delete(h.pendingWork, userID)
-		h.mu.Unlock()
-
-		// Edit the message to show confirmation
-		edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
-			fmt.Sprintf("✅ Work set to: %s", siteName))
-		if _, err := api.Send(edit); err != nil {
-			log.Printf("HandleCallback: error editing message: %v", err)
-		}
-		log.Printf("HandleCallback: saved work site %d (%s) for user %d", siteID, siteName, userID)
+	data, err := decodeCallback(callback.Data)
+	if err != nil {
+		log.Printf("HandleCallback: %v", err)
+		return
 	}
+	handle, ok := h.callbacks[data.Action]
+	if !ok {
+		log.Printf("HandleCallback: unknown action %q", data.Action)
+		return
+	}
+	handle(ctx, api, callback, data)
 }
 
 // sendMessage is a helper to send a Telegram message.
@@ -448,7 +637,35 @@ func (h *Handler) sendMessage(api *tgbotapi.BotAPI, chatID int64, text string) {
 	}
 }
 
+// rawArgs returns the arguments after a command prefix with their original casing.
+// HandleMessage lowercases text for matching, but free text such as notes should keep its case.
+func rawArgs(raw, prefix string) string {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < len(prefix) {
+		return ""
+	}
+	return strings.TrimSpace(raw[len(prefix):])
+}
+
 // SetSites allows injecting a pre-fetched list of sites into the handler (used at startup).
 func (h *Handler) SetSites(sites []sl.Site) {
-	h.sites = sites
+	h.setSites(sites)
+}
+
+// setSites replaces the sites cache with a freshly built search index.
+func (h *Handler) setSites(sites []sl.Site) {
+	index := sl.NewSiteIndex(sites)
+
+	h.mu.Lock()
+	h.siteIndex = index
+	h.mu.Unlock()
+}
+
+// sitesIndex returns the current sites index. Indexes are never mutated after
+// construction, so callers can use the result without holding the lock.
+func (h *Handler) sitesIndex() *sl.SiteIndex {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.siteIndex
 }
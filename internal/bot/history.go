@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// sendPush sends a message the user didn't ask for (kind is e.g. "briefing")
+// and records it, delivered or not, in their /history.
+func (h *Handler) sendPush(api *tgbotapi.BotAPI, userID, chatID int64, kind, text string) {
+	rec := store.PushRecord{Sent: time.Now(), Kind: kind, ChatID: chatID, Text: text, Delivered: true}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("sendPush: error sending %s to user %d: %v", kind, userID, err)
+		rec.Delivered = false
+		rec.Error = err.Error()
+	}
+
+	if err := h.userStore.RecordPush(userID, rec); err != nil {
+		log.Printf("sendPush: error recording %s for user %d: %v", kind, userID, err)
+	}
+}
+
+// handleHistory lists the user's recent briefings and alerts, newest first.
+func (h *Handler) handleHistory(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	history := h.userStore.PushHistory(userID)
+	if len(history) == 0 {
+		h.sendMessage(api, chatID, "No briefings or alerts sent to you yet.")
+		return
+	}
+
+	loc := scheduler.Stockholm()
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗂 Your last %d briefings and alerts:\n", len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		rec := history[i]
+		status := "✅"
+		if !rec.Delivered {
+			status = "❌ not delivered"
+		}
+		// The first line is usually the board's title, e.g. "🚌 Next buses to work:".
+		title, _, _ := strings.Cut(rec.Text, "\n")
+		fmt.Fprintf(&b, "\n• %s %s %s\n  %s", rec.Sent.In(loc).Format("Mon 2 Jan 15:04"), rec.Kind, status, title)
+	}
+
+	// Plain text: stored texts are truncated and may break Markdown.
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("handleHistory: error sending message: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// Inline results: at most inlineResults stops per query, each with its next
+// inlineDepartures departures. Telegram may reuse an answer for inlineCacheSeconds.
+const (
+	inlineResults      = 3
+	inlineDepartures   = 3
+	inlineCacheSeconds = 30
+)
+
+// HandleInlineQuery answers "@bot <stop>" typed in any chat with one article
+// per matching stop; choosing one posts that stop's departure board into the
+// chat. An empty query offers the user's home and work stops. Inline mode must
+// be enabled for the bot with BotFather (/setinline).
+func (h *Handler) HandleInlineQuery(ctx context.Context, api *tgbotapi.BotAPI, query *tgbotapi.InlineQuery) {
+	ctx, info := h.withRequest(ctx, query.From, 0)
+	if h.checkAccess(info) != accessAllowed {
+		log.Printf("HandleInlineQuery: user %d not allowed, ignoring", info.UserID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, boardCommandTimeout)
+	defer cancel()
+
+	sites, err := h.inlineSites(ctx, info.UserID, strings.TrimSpace(query.Query))
+	if err != nil {
+		log.Printf("HandleInlineQuery: error fetching sites: %v", err)
+		return
+	}
+
+	th := h.thresholdsFor(info.UserID)
+	results := make([]interface{}, 0, len(sites))
+	for _, site := range sites {
+		departures, err := h.slClient.GetDepartures(ctx, strconv.Itoa(site.SiteID))
+		if err != nil {
+			log.Printf("HandleInlineQuery: error fetching departures for %d: %v", site.SiteID, err)
+			continue
+		}
+		departures = sl.DedupJourneys(departures)
+
+		board := sl.FormatDepartures(departures, inlineDepartures, th)
+		if board == "" {
+			board = "No upcoming departures.\n"
+		}
+		article := tgbotapi.NewInlineQueryResultArticleMarkdown(
+			strconv.Itoa(site.SiteID),
+			site.Name,
+			fmt.Sprintf("🚏 *%s*\n\n%s", site.Name, board),
+		)
+		article.Description, _, _ = strings.Cut(board, "\n")
+		results = append(results, article)
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheSeconds,
+		IsPersonal:    true, // results depend on the user's saved stops and thresholds
+	}
+	if _, err := api.Request(answer); err != nil {
+		log.Printf("HandleInlineQuery: error answering query: %v", err)
+	}
+}
+
+// inlineSites picks the stops to offer for an inline query.
+func (h *Handler) inlineSites(ctx context.Context, userID int64, query string) ([]sl.Site, error) {
+	if query == "" {
+		prefs := h.userStore.GetPrefs(userID)
+		var sites []sl.Site
+		for _, saved := range []struct{ label, siteID string }{{"Home", prefs.HomeSiteID}, {"Work", prefs.WorkSiteID}} {
+			if id, err := strconv.Atoi(saved.siteID); err == nil {
+				sites = append(sites, sl.Site{Name: saved.label, SiteID: id})
+			}
+		}
+		return sites, nil
+	}
+
+	if h.sitesIndex().Len() == 0 {
+		sites, err := h.slClient.GetSites(ctx)
+		if err != nil {
+			return nil, err
+		}
+		h.setSites(sites)
+	}
+	return h.sitesIndex().Match(query, inlineResults), nil
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// The lines layout shows up to boardLines lines with their next boardLineTimes departures.
+const (
+	boardLines     = 4
+	boardLineTimes = 3
+)
+
+// handleLayout shows or sets the user's board layout: "/layout lines" or "/layout list".
+func (h *Handler) handleLayout(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		layout := h.userStore.GetPrefs(userID).Layout
+		if layout == "" {
+			layout = store.LayoutList
+		}
+		h.sendMessage(api, chatID, fmt.Sprintf("🗂 Boards use the %s layout. Change with /layout list or /layout lines.", layout))
+		return
+	}
+	if args != store.LayoutList && args != store.LayoutLines {
+		h.sendMessage(api, chatID, "❓ Usage: /layout list|lines\nlist: one row per departure\nlines: one row per line, e.g. 515 → Odenplan: 3, 11, 19 min")
+		return
+	}
+
+	if err := h.userStore.SetLayout(userID, args); err != nil {
+		log.Printf("handleLayout: error saving layout: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ Boards now use the %s layout.", args))
+}
+
+// formatLineBoard renders departures one row per line and direction, with
+// disruption notes under the line they affect.
+func formatLineBoard(siteID string, departures []sl.Departure, now time.Time, th format.Thresholds) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	more := false
+	groups := sl.GroupByLine(departures)
+	for _, g := range groups[:min(boardLines, len(groups))] {
+		b.WriteString(sl.FormatLineGroupRelative(g, boardLineTimes, now, th) + "\n")
+		lines, hidden := deviationLines(g.Departures[0], seen)
+		b.WriteString(lines)
+		more = more || hidden
+	}
+	if more {
+		fmt.Fprintf(&b, "ℹ️ More disruption info: /deviations\\_%s\n", siteID)
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// handleLeave arms a departure alarm by line instead of by button:
+// "/leave 515" picks the next 515 from the home or work stop that the user
+// can still make, given their /walk time, and alerts them when to set off.
+// "/leave" lists armed alarms and "/leave off" cancels them all.
+func (h *Handler) handleLeave(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	usage := "❓ Usage: /leave <line> [home|work], /leave to list, /leave off to cancel"
+
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		h.listAlarms(api, chatID, userID)
+		return
+	case len(fields) == 1 && (fields[0] == "off" || fields[0] == "cancel"):
+		h.cancelAlarms(api, chatID, userID)
+		return
+	case len(fields) > 2:
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	line := fields[0]
+	prefs := h.userStore.GetPrefs(userID)
+	labels := []string{"home", "work"}
+	if len(fields) == 2 {
+		if _, ok := h.savedSiteID(prefs, fields[1]); !ok {
+			h.sendMessage(api, chatID, usage)
+			return
+		}
+		labels = fields[1:]
+	}
+
+	// The earliest departure of the line the user can still reach, over the stops asked for.
+	now := time.Now()
+	var best *alarm
+	var bestWalk time.Duration
+	checked := make(map[string]bool)
+	for _, label := range labels {
+		siteID, _ := h.savedSiteID(prefs, label)
+		if siteID == "" || checked[siteID] {
+			continue
+		}
+		checked[siteID] = true
+
+		departures, err := h.slClient.GetDepartures(ctx, siteID)
+		if err != nil {
+			log.Printf("handleLeave: site=%s: %v", siteID, err)
+			continue
+		}
+		walk := time.Duration(prefs.WalkMinutes[siteID]) * time.Minute
+		for _, dep := range sl.DedupJourneys(departures) {
+			if !strings.EqualFold(dep.Line, line) || dep.Journey.State == "CANCELLED" {
+				continue
+			}
+			a := &alarm{userID: userID, chatID: chatID, siteID: siteID, key: departureKey(dep), dep: dep, lead: walk + alarmBuffer}
+			if a.fireAt().After(now) && (best == nil || dep.Expected.Before(best.dep.Expected)) {
+				best, bestWalk = a, walk
+			}
+		}
+	}
+	if best == nil {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No upcoming %s you can still make from your %s stop.",
+			strings.ToUpper(line), strings.Join(labels, " or ")))
+		return
+	}
+
+	if !h.armAlarm(best) {
+		h.sendMessage(api, chatID, tooManyAlarmsText(h.userStore.Limits().AlarmsPerUser))
+		return
+	}
+
+	h.sendMessage(api, chatID, alarmSetText(best, bestWalk)+" Cancel with /leave off.")
+}
+
+// listAlarms shows the user's armed departure alarms, soonest first.
+func (h *Handler) listAlarms(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	h.mu.RLock()
+	var lines []string
+	var mine []*alarm
+	for _, a := range h.alarms {
+		if a.userID == userID {
+			mine = append(mine, a)
+		}
+	}
+	sort.Slice(mine, func(i, j int) bool { return mine[i].fireAt().Before(mine[j].fireAt()) })
+	for _, a := range mine {
+		lines = append(lines, fmt.Sprintf("⏰ %s: %s → %s at %s", format.Clock(a.fireAt()),
+			a.dep.Line, a.dep.Direction, format.Clock(a.dep.Expected)))
+	}
+	h.mu.RUnlock()
+
+	if len(lines) == 0 {
+		h.sendMessage(api, chatID, "No departure alarms. Set one with /leave <line>, or ⏰ under a board.")
+		return
+	}
+	h.sendMessage(api, chatID, "Your departure alarms:\n"+strings.Join(lines, "\n")+"\n\nCancel them with /leave off.")
+}
+
+// cancelAlarms drops all of the user's departure alarms.
+func (h *Handler) cancelAlarms(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	h.mu.Lock()
+	cancelled := 0
+	for id, a := range h.alarms {
+		if a.userID == userID {
+			delete(h.alarms, id)
+			cancelled++
+		}
+	}
+	h.mu.Unlock()
+
+	if cancelled == 0 {
+		h.sendMessage(api, chatID, "No departure alarms to cancel.")
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("🔕 Cancelled %d departure alarm(s).", cancelled))
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// Stops further than nearbyRadius aren't "nearby"; at most nearbyStops are shown.
+const (
+	nearbyRadius = 1000.0 // meters
+	nearbyStops  = 3
+)
+
+// handleNearby asks for the user's location with a one-tap keyboard button.
+// The answer arrives as a location message, see handleLocation.
+func (h *Handler) handleNearby(api *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "📍 Share your location (or a live location) and I'll show the closest stops.")
+	msg.ReplyMarkup = tgbotapi.NewOneTimeReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonLocation("📍 Send my location")),
+	)
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("handleNearby: error sending message: %v", err)
+	}
+}
+
+// handleLocation answers a shared location with the closest stops and their
+// next departures.
+func (h *Handler) handleLocation(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, loc *tgbotapi.Location) {
+	if h.sitesIndex().Len() == 0 {
+		sites, err := h.slClient.GetSites(ctx)
+		if err != nil {
+			log.Printf("handleLocation: error fetching sites: %v", err)
+			h.sendMessage(api, chatID, "❌ Error fetching sites. Try again later.")
+			return
+		}
+		h.setSites(sites)
+	}
+
+	nearby := sl.Nearest(h.sitesIndex().Sites(), loc.Latitude, loc.Longitude, nearbyStops, nearbyRadius)
+	if len(nearby) == 0 {
+		h.sendNearby(api, chatID, fmt.Sprintf("❌ No stops within %s of you.", format.Distance(nearbyRadius)))
+		return
+	}
+
+	th := h.thresholdsFor(userID)
+	var b strings.Builder
+	b.WriteString("🚏 Stops near you:\n")
+	for _, stop := range nearby {
+		fmt.Fprintf(&b, "\n📍 *%s* · %s\n", stop.Name, format.Distance(stop.Meters))
+
+		departures, err := h.slClient.GetDepartures(ctx, strconv.Itoa(stop.SiteID))
+		if err != nil {
+			log.Printf("handleLocation: error fetching departures for %d: %v", stop.SiteID, err)
+			b.WriteString("❌ Departures unavailable right now.\n")
+			continue
+		}
+		if len(departures) == 0 {
+			b.WriteString("No upcoming departures.\n")
+			continue
+		}
+		b.WriteString(sl.FormatDepartures(sl.DedupJourneys(departures), 2, th))
+	}
+
+	h.sendNearby(api, chatID, b.String())
+}
+
+// sendNearby sends text and removes the location keyboard left by handleNearby.
+func (h *Handler) sendNearby(api *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("sendNearby: error sending message: %v", err)
+	}
+}
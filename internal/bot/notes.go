@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// handleAddNote attaches a tip to a stop for everyone in this chat.
+// Syntax: "/note <stop> | <text>" or "/note <stop> | <line> | <text>".
+func (h *Handler) handleAddNote(ctx context.Context, api *tgbotapi.BotAPI, msg *tgbotapi.Message, args string) {
+	chatID := msg.Chat.ID
+	parts := strings.Split(args, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	var stopQuery, line, text string
+	switch len(parts) {
+	case 2:
+		stopQuery, text = parts[0], parts[1]
+	case 3:
+		stopQuery, line, text = parts[0], parts[1], parts[2]
+	}
+	if stopQuery == "" || text == "" {
+		h.sendMessage(api, chatID, "❓ Usage: /note <stop> | [line |] <tip>")
+		return
+	}
+
+	// Group chats must opt in; in a private chat the only reader is the author.
+	if msg.Chat.IsPrivate() {
+		if !h.userStore.NotesShared(chatID) {
+			if err := h.userStore.SetNotesShared(chatID, true); err != nil {
+				log.Printf("handleAddNote: error enabling notes: %v", err)
+			}
+		}
+	} else if !h.userStore.NotesShared(chatID) {
+		h.sendMessage(api, chatID, "🔒 Notes are off in this group. Enable them with /sharenotes on")
+		return
+	}
+
+	site, ok := h.resolveSingleSite(ctx, api, chatID, stopQuery)
+	if !ok {
+		return
+	}
+
+	note := store.StopNote{
+		SiteID:   site.SiteID,
+		Line:     line,
+		Text:     text,
+		AuthorID: msg.From.ID,
+		Created:  time.Now(),
+	}
+	if err := h.userStore.AddNote(chatID, note); err != nil {
+		log.Printf("handleAddNote: error saving note: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving note. Try again later.")
+		return
+	}
+
+	log.Printf("handleAddNote: chat=%d site=%d line=%q", chatID, site.SiteID, line)
+	h.sendMessage(api, chatID, fmt.Sprintf("📝 Note added for %s", escapeMarkdown(site.Name)))
+}
+
+// handleListNotes lists this chat's notes with the numbers /delnote expects.
+func (h *Handler) handleListNotes(ctx context.Context, api *tgbotapi.BotAPI, chatID int64) {
+	notes := h.userStore.Notes(chatID)
+	if len(notes) == 0 {
+		h.sendMessage(api, chatID, "No notes yet. Add one with /note <stop> | <tip>")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Notes in this chat:\n")
+	for i, n := range notes {
+		stop := escapeMarkdown(h.siteNameByID(ctx, strconv.Itoa(n.SiteID)))
+		if n.Line != "" {
+			stop = fmt.Sprintf("%s (line %s)", stop, escapeMarkdown(n.Line))
+		}
+		fmt.Fprintf(&b, "%d. %s: %s\n", i+1, stop, escapeMarkdown(n.Text))
+	}
+	if !h.userStore.NotesShared(chatID) {
+		b.WriteString("\nNotes are currently hidden. Enable them with /sharenotes on")
+	}
+	h.sendMessage(api, chatID, b.String())
+}
+
+// handleDeleteNote removes a note by its number in /notes.
+func (h *Handler) handleDeleteNote(api *tgbotapi.BotAPI, chatID int64, args string) {
+	n, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil || n < 1 {
+		h.sendMessage(api, chatID, "❓ Usage: /delnote <number from /notes>")
+		return
+	}
+
+	if err := h.userStore.DeleteNote(chatID, n-1); err != nil {
+		log.Printf("handleDeleteNote: %v", err)
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No note number %d.", n))
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("🗑 Note %d deleted.", n))
+}
+
+// handleShareNotes turns shared notes on or off for the chat.
+func (h *Handler) handleShareNotes(api *tgbotapi.BotAPI, chatID int64, arg string) {
+	var shared bool
+	switch strings.TrimSpace(arg) {
+	case "on":
+		shared = true
+	case "off":
+		shared = false
+	default:
+		h.sendMessage(api, chatID, "❓ Usage: /sharenotes on|off")
+		return
+	}
+
+	if err := h.userStore.SetNotesShared(chatID, shared); err != nil {
+		log.Printf("handleShareNotes: error saving: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	if shared {
+		h.sendMessage(api, chatID, "✅ Stop notes are now shown in this chat.")
+	} else {
+		h.sendMessage(api, chatID, "✅ Stop notes are now hidden in this chat.")
+	}
+}
+
+// stopTips renders the chat's notes relevant to a departure board.
+// Line-specific notes only appear when that line is among the shown departures.
+// Note text is escaped, since boards are sent as Markdown.
+func (h *Handler) stopTips(chatID int64, siteID string, shown []sl.Departure) string {
+	if !h.userStore.NotesShared(chatID) {
+		return ""
+	}
+	id, err := strconv.Atoi(siteID)
+	if err != nil {
+		return ""
+	}
+
+	lines := make(map[string]bool)
+	for _, dep := range shown {
+		lines[dep.Line] = true
+	}
+
+	var b strings.Builder
+	for _, n := range h.userStore.Notes(chatID) {
+		if n.SiteID != id || (n.Line != "" && !lines[n.Line]) {
+			continue
+		}
+		fmt.Fprintf(&b, "\n💡 %s", escapeMarkdown(n.Text))
+	}
+	return b.String()
+}
+
+// resolveSingleSite fuzzy-matches query and returns a site only when the choice is unambiguous.
+// On failure it has already told the user what went wrong.
+func (h *Handler) resolveSingleSite(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, query string) (sl.Site, bool) {
+	if h.sitesIndex().Len() == 0 {
+		sites, err := h.slClient.GetSites(ctx)
+		if err != nil {
+			log.Printf("resolveSingleSite: error fetching sites: %v", err)
+			h.sendMessage(api, chatID, "❌ Error fetching sites. Try again later.")
+			return sl.Site{}, false
+		}
+		h.setSites(sites)
+	}
+
+	matches := h.sitesIndex().Match(query, 5)
+	if len(matches) == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No sites found matching '%s'", query))
+		return sl.Site{}, false
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+
+	// Prefer an exact name match over asking again.
+	for _, m := range matches {
+		if strings.EqualFold(m.Name, query) {
+			return m, true
+		}
+	}
+
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.Name
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("Multiple matches for '%s': %s. Please be more specific.", query, strings.Join(names, ", ")))
+	return sl.Site{}, false
+}
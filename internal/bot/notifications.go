@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// notificationLabels are the button labels for each store.NotificationCategory.
+var notificationLabels = map[store.NotificationCategory]string{
+	store.NotifyBriefings:     "Subscription briefings",
+	store.NotifyDisruptions:   "Line disruption alerts",
+	store.NotifyServiceAlerts: "SL outage notices",
+}
+
+// handleNotifications shows the user's notification toggles as inline buttons.
+func (h *Handler) handleNotifications(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	msg := tgbotapi.NewMessage(chatID, "🔔 Notifications. Tap one to switch it on or off:")
+	msg.ReplyMarkup = h.notificationButtons(userID)
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("handleNotifications: error sending message: %v", err)
+	}
+}
+
+// notificationButtons renders one toggle button per category with its current state.
+func (h *Handler) notificationButtons(userID int64) tgbotapi.InlineKeyboardMarkup {
+	prefs := h.userStore.GetPrefs(userID).Notifications
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range store.NotificationCategories {
+		state := "✅"
+		if !prefs.Enabled(c) {
+			state = "🔕"
+		}
+		data := callbackData{Version: callbackVersion, Action: "notify", Arg: string(c)}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(state+" "+notificationLabels[c], data.encode()),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleNotifyToggle flips one category for the user who pressed the button
+// and redraws the buttons.
+func (h *Handler) handleNotifyToggle(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, data callbackData) {
+	userID := callback.From.ID
+	category := store.NotificationCategory(data.Arg)
+	enabled := h.userStore.GetPrefs(userID).Notifications.Enabled(category)
+	if err := h.userStore.SetNotification(userID, category, !enabled); err != nil {
+		log.Printf("handleNotifyToggle: user=%d category=%q: %v", userID, category, err)
+		h.sendMessage(api, callback.Message.Chat.ID, "❌ Error saving preference.")
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, h.notificationButtons(userID))
+	if _, err := api.Send(edit); err != nil {
+		log.Printf("handleNotifyToggle: error editing markup: %v", err)
+	}
+}
+
+// notificationsEnabled reports whether chatID wants category. Group chats have
+// no preferences of their own and always get notifications.
+func (h *Handler) notificationsEnabled(chatID int64, category store.NotificationCategory) bool {
+	return h.userStore.GetPrefs(chatID).Notifications.Enabled(category)
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/sl"
+)
+
+// handleFrom handles "from <place> to <place>": the same departure board as
+// "to work"/"to home", but from a temporary origin and showing only the buses
+// that go on to the destination. Either end may be one of the user's saved
+// places ("from gym to home"); the origin may also be any stop name.
+// Saved prefs are left untouched.
+func (h *Handler) handleFrom(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, rest string) {
+	i := strings.LastIndex(rest, " to ")
+	if i < 0 {
+		h.sendMessage(api, chatID, "❓ Usage: from <place> to <place>")
+		return
+	}
+	place := strings.TrimSpace(rest[:i])
+	dest := strings.TrimSpace(rest[i+len(" to "):])
+	if place == "" || dest == "" {
+		h.sendMessage(api, chatID, "❓ Usage: from <place> to <place>")
+		return
+	}
+
+	places := h.userStore.GetPrefs(userID).Places
+	if dest != "work" && dest != "home" {
+		alias, _, ok := matchPlace(places, dest)
+		if !ok {
+			h.sendMessage(api, chatID, fmt.Sprintf("❌ I don't know the place '%s'. %s", dest, knownPlacesHint(places)))
+			return
+		}
+		dest = alias
+	}
+
+	// A saved place wins over a stop-name search.
+	if _, saved, ok := matchPlace(places, place); ok {
+		h.sendFromBoard(ctx, api, chatID, userID, sl.Site{Name: saved.SiteName, SiteID: saved.SiteID}, dest)
+		return
+	}
+
+	if h.sitesIndex().Len() == 0 {
+		sites, err := h.slClient.GetSites(ctx)
+		if err != nil {
+			log.Printf("handleFrom: error fetching sites: %v", err)
+			h.sendMessage(api, chatID, "❌ Error fetching sites. Try again later.")
+			return
+		}
+		h.setSites(sites)
+	}
+
+	matches := h.sitesIndex().Match(place, 3)
+	if len(matches) == 0 {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No sites or saved places matching '%s'. %s", place, knownPlacesHint(places)))
+		return
+	}
+
+	if len(matches) == 1 {
+		h.sendFromBoard(ctx, api, chatID, userID, matches[0], dest)
+		return
+	}
+
+	// Multiple matches: ask which stop, like /sethome does.
+	h.mu.Lock()
+	h.pendingFrom[userID] = matches
+	h.pendingFromDest[userID] = dest
+	h.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Multiple matches for '%s'. Which one?", place))
+	msg.ReplyMarkup = siteButtons("from", userID, matches, false)
+	if _, err := api.Send(msg); err != nil {
+		log.Printf("handleFrom: error sending button message: %v", err)
+	}
+}
+
+// sendFromBoard sends the departure board for a temporary origin, limited to
+// buses towards dest: "home", "work" or one of userID's saved places.
+func (h *Handler) sendFromBoard(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, origin sl.Site, dest string) {
+	siteID := fmt.Sprintf("%d", origin.SiteID)
+	label := fmt.Sprintf("%s from %s", dest, origin.Name)
+
+	prefs := h.userStore.GetPrefs(userID)
+	towards, ok := h.savedSiteID(prefs, dest)
+	if !ok {
+		towards = fmt.Sprintf("%d", prefs.Places[dest].SiteID)
+	}
+
+	message, shown, err := h.departureBoardTowards(ctx, chatID, siteID, label, towards)
+	if err != nil {
+		log.Printf("sendFromBoard: error fetching departures for %d: %v", origin.SiteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+	h.sendBoard(api, chatID, siteID, label, towards, message, shown)
+}
+
+// towardsStop returns the departures that go on to call at the stop towards,
+// or nil if none are known to or its departures can't be fetched.
+func (h *Handler) towardsStop(ctx context.Context, departures []sl.Departure, towards string) []sl.Departure {
+	destination, err := h.slClient.GetDepartures(ctx, towards)
+	if err != nil {
+		log.Printf("towardsStop: site=%s: %v", towards, err)
+		return nil
+	}
+	return sl.TowardsStop(departures, destination)
+}
+
+// handleFromCallback resolves a "from" button press into a departure board.
+func (h *Handler) handleFromCallback(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, userID int64, siteID int) {
+	h.mu.Lock()
+	matches := h.pendingFrom[userID]
+	dest := h.pendingFromDest[userID]
+	delete(h.pendingFrom, userID)
+	delete(h.pendingFromDest, userID)
+	h.mu.Unlock()
+
+	for _, site := range matches {
+		if site.SiteID != siteID {
+			continue
+		}
+
+		edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID,
+			fmt.Sprintf("📍 From %s", site.Name))
+		if _, err := api.Send(edit); err != nil {
+			log.Printf("handleFromCallback: error editing message: %v", err)
+		}
+		h.sendFromBoard(ctx, api, callback.Message.Chat.ID, userID, site, dest)
+		return
+	}
+
+	h.sendMessage(api, callback.Message.Chat.ID, "❌ Site not found in pending selections.")
+}
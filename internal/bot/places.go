@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// handleSetPlace saves a place alias: "/place <alias> <stop>".
+func (h *Handler) handleSetPlace(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	alias, query, _ := strings.Cut(strings.TrimSpace(args), " ")
+	query = strings.TrimSpace(query)
+	if alias == "" || query == "" {
+		h.sendMessage(api, chatID, "❓ Usage: /place <alias> <stop>, e.g. /place gym Fridhemsplan")
+		return
+	}
+	if alias == "home" || alias == "work" {
+		h.sendMessage(api, chatID, "❓ Use /sethome or /setwork for those.")
+		return
+	}
+
+	site, ok := h.resolveSingleSite(ctx, api, chatID, query)
+	if !ok {
+		return
+	}
+
+	if err := h.userStore.SetPlace(userID, alias, store.SavedPlace{SiteID: site.SiteID, SiteName: site.Name}); err != nil {
+		log.Printf("handleSetPlace: error saving place: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ Saved '%s' as %s", alias, site.Name))
+}
+
+// handleListPlaces lists the user's saved places.
+func (h *Handler) handleListPlaces(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	places := h.userStore.GetPrefs(userID).Places
+	if len(places) == 0 {
+		h.sendMessage(api, chatID, "No saved places. Add one with /place <alias> <stop>")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Your places:\n")
+	for _, alias := range sortedAliases(places) {
+		place := places[alias]
+		fmt.Fprintf(&b, "• %s → %s", alias, place.SiteName)
+		if !place.Expires.IsZero() {
+			fmt.Fprintf(&b, " (guest, until %s)", place.Expires.In(scheduler.Stockholm()).Format("Mon 15:04"))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nUse them like: from <place> to home")
+	h.sendMessage(api, chatID, b.String())
+}
+
+// handleDeletePlace removes a saved place.
+func (h *Handler) handleDeletePlace(api *tgbotapi.BotAPI, chatID int64, userID int64, alias string) {
+	alias = strings.TrimSpace(alias)
+	if err := h.userStore.DeletePlace(userID, alias); err != nil {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No place called '%s'.", alias))
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("🗑 Place '%s' deleted.", alias))
+}
+
+// matchPlace resolves a (possibly misspelled) alias against the user's places.
+// It tries an exact match, then a unique prefix, then a unique alias within one edit.
+func matchPlace(places map[string]store.SavedPlace, query string) (string, store.SavedPlace, bool) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if p, ok := places[query]; ok {
+		return query, p, true
+	}
+
+	var prefixed, close []string
+	for alias := range places {
+		if strings.HasPrefix(alias, query) {
+			prefixed = append(prefixed, alias)
+		}
+		if len(query) >= 3 && editDistance(alias, query) <= 1 {
+			close = append(close, alias)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], places[prefixed[0]], true
+	}
+	if len(close) == 1 {
+		return close[0], places[close[0]], true
+	}
+	return "", store.SavedPlace{}, false
+}
+
+// knownPlacesHint lists the user's places for "not found" replies.
+func knownPlacesHint(places map[string]store.SavedPlace) string {
+	if len(places) == 0 {
+		return "You have no saved places yet; add one with /place <alias> <stop>."
+	}
+	return "Known places: home, work, " + strings.Join(sortedAliases(places), ", ") + "."
+}
+
+func sortedAliases(places map[string]store.SavedPlace) []string {
+	aliases := make([]string, 0, len(places))
+	for alias := range places {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// editDistance is the Levenshtein distance between a and b, by rune.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxInlineRaw is the largest raw response sent as a message; Telegram caps
+// messages at 4096 characters, so anything bigger goes out as a file.
+const maxInlineRaw = 3500
+
+// handleRawDepartures sends the upstream departures JSON for a stop (admin only),
+// for debugging how SL responses map onto departure boards.
+func (h *Handler) handleRawDepartures(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, query string) {
+	site, ok := h.resolveSingleSite(ctx, api, chatID, query)
+	if !ok {
+		return
+	}
+
+	raw, err := h.slClient.RawDepartures(ctx, strconv.Itoa(site.SiteID))
+	if err != nil {
+		log.Printf("handleRawDepartures: site=%d: %v", site.SiteID, err)
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ Error fetching departures: %v", err))
+		return
+	}
+
+	if len(raw) <= maxInlineRaw {
+		// Sent without Markdown: JSON is full of characters Markdown would mangle.
+		if _, err := api.Send(tgbotapi.NewMessage(chatID, string(raw))); err != nil {
+			log.Printf("handleRawDepartures: error sending message: %v", err)
+		}
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("departures-%d.json", site.SiteID),
+		Bytes: raw,
+	})
+	doc.Caption = fmt.Sprintf("Raw departures for %s (%d bytes)", site.Name, len(raw))
+	if _, err := api.Send(doc); err != nil {
+		log.Printf("handleRawDepartures: error sending document: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// boardContextTTL is how long a departure board's buttons keep working.
+const boardContextTTL = 6 * time.Hour
+
+// reverseDest maps the boards that have an opposite direction to it.
+var reverseDest = map[string]string{"home": "work", "work": "home"}
+
+// boardQuery encodes what a board shows for the message context store:
+// "<siteID>|<dest>", plus "|<towards>" on boards limited to buses calling at
+// another stop (see departureBoardTowards).
+func boardQuery(siteID, dest, towards string) string {
+	if towards != "" {
+		return siteID + "|" + dest + "|" + towards
+	}
+	return siteID + "|" + dest
+}
+
+// parseBoardQuery is the inverse of boardQuery.
+func parseBoardQuery(query string) (siteID, dest, towards string, ok bool) {
+	siteID, rest, ok := strings.Cut(query, "|")
+	if i := strings.LastIndex(rest, "|"); i >= 0 {
+		return siteID, rest[:i], rest[i+1:], ok
+	}
+	return siteID, rest, "", ok
+}
+
+// boardContext returns the stop, label and destination stop of the board a
+// button was pressed on, telling the user when the board is too old to act on.
+func (h *Handler) boardContext(api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) (siteID, dest, towards string, ok bool) {
+	chatID := callback.Message.Chat.ID
+	query, found := h.userStore.MessageContext(chatID, callback.Message.MessageID)
+	siteID, dest, towards, valid := parseBoardQuery(query)
+	if !found || !valid {
+		h.sendMessage(api, chatID, "❌ This board is too old to update. Ask again, e.g. \"to work\".")
+		return "", "", "", false
+	}
+	return siteID, dest, towards, true
+}
+
+// handleRefresh re-fetches the departures on a board and edits it in place,
+// so "to work" needn't be typed again to see updated times.
+func (h *Handler) handleRefresh(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, data callbackData) {
+	siteID, dest, towards, ok := h.boardContext(api, callback)
+	if !ok {
+		return
+	}
+	h.editBoard(ctx, api, callback, siteID, dest, towards)
+}
+
+// handleSwap turns a "to work" board into the "to home" one and back, for when
+// the wrong direction was asked for. Refresh then follows the new direction.
+func (h *Handler) handleSwap(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, data callbackData) {
+	_, dest, _, ok := h.boardContext(api, callback)
+	if !ok {
+		return
+	}
+	other, ok := reverseDest[dest]
+	if !ok {
+		return
+	}
+	siteID, _ := h.savedSiteID(h.userStore.GetPrefs(callback.From.ID), other)
+
+	chatID := callback.Message.Chat.ID
+	if err := h.userStore.SetMessageContext(chatID, callback.Message.MessageID, boardQuery(siteID, other, ""), boardContextTTL); err != nil {
+		log.Printf("handleSwap: error saving message context: %v", err)
+	}
+	h.editBoard(ctx, api, callback, siteID, other, "")
+}
+
+// editBoard replaces the board a button was pressed on with the current
+// departures at siteID (towards the stop towards, if set), with buttons rebuilt
+// for the departures now shown.
+func (h *Handler) editBoard(ctx context.Context, api *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, siteID, dest, towards string) {
+	chatID := callback.Message.Chat.ID
+
+	message, shown, err := h.departureBoardTowards(ctx, chatID, siteID, dest, towards)
+	if err != nil {
+		log.Printf("editBoard: site=%s: %v", siteID, err)
+		h.sendMessage(api, chatID, "❌ Error fetching departures. Try again later.")
+		return
+	}
+	message += fmt.Sprintf("\n_🔄 Updated %s_", format.Clock(time.Now()))
+
+	h.edits.edit(api, chatID, callback.Message.MessageID, message, boardMarkup(siteID, dest, shown))
+}
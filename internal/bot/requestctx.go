@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RequestInfo carries per-update metadata so deep helpers (formatting, notifiers)
+// can read user settings from the context instead of threading extra parameters.
+type RequestInfo struct {
+	UserID   int64
+	ChatID   int64
+	Language string // Telegram client language code, e.g. "sv" or "en"; may be empty
+	Admin    bool   // entitlement: may run admin commands
+}
+
+// requestInfoKey is unexported so only this package can set the value.
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the RequestInfo stored in ctx, if any.
+func RequestInfoFrom(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+// withRequest populates the request context for an incoming update.
+// It is the one place user metadata is gathered before dispatching.
+func (h *Handler) withRequest(ctx context.Context, from *tgbotapi.User, chatID int64) (context.Context, RequestInfo) {
+	info := RequestInfo{ChatID: chatID}
+	if from != nil {
+		info.UserID = from.ID
+		info.Language = from.LanguageCode
+		info.Admin = h.isAdmin(from.ID)
+	}
+	return WithRequestInfo(ctx, info), info
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// RunSaveRetry retries a failed store save on every tick. Admins are told once
+// when saving starts failing and again when it recovers; users aren't bothered,
+// since their changes are kept in memory meanwhile. Stores that don't
+// implement store.SaveRetrier are left alone. Register it with a scheduler.Scheduler.
+func (h *Handler) RunSaveRetry(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	retrier, ok := h.userStore.(store.SaveRetrier)
+	if !ok {
+		return
+	}
+	since, _, pending := retrier.PendingSave()
+	if !pending {
+		// Any other write may have saved the file since the last tick.
+		h.saveRecovered(api)
+		return
+	}
+
+	err := retrier.RetrySave()
+	if err == nil {
+		log.Printf("RunSaveRetry: saved after failing since %s", since.Format(time.RFC3339))
+		h.saveRecovered(api)
+		return
+	}
+
+	h.mu.Lock()
+	alerted := !h.saveAlertSince.IsZero()
+	if !alerted {
+		h.saveAlertSince = since
+	}
+	h.mu.Unlock()
+
+	if !alerted {
+		log.Printf("RunSaveRetry: still failing: %v", err)
+		// The error names the prefs file, whose path may hold Markdown characters.
+		h.notifyAdmins(api, fmt.Sprintf("⚠️ Saving preferences has failed since %s: %s\nChanges are kept in memory and retried.",
+			format.Clock(since), escapeMarkdown(err.Error())))
+	}
+}
+
+// saveRecovered tells admins that saves work again, if they were told they didn't.
+func (h *Handler) saveRecovered(api *tgbotapi.BotAPI) {
+	h.mu.Lock()
+	since := h.saveAlertSince
+	h.saveAlertSince = time.Time{}
+	h.mu.Unlock()
+
+	if !since.IsZero() {
+		h.notifyAdmins(api, fmt.Sprintf("✅ Preferences are being saved again (failing since %s).", format.Clock(since)))
+	}
+}
+
+// notifyAdmins sends text to every admin; admin user IDs double as their private chat IDs.
+func (h *Handler) notifyAdmins(api *tgbotapi.BotAPI, text string) {
+	h.mu.RLock()
+	admins := make([]int64, 0, len(h.admins))
+	for id := range h.admins {
+		admins = append(admins, id)
+	}
+	h.mu.RUnlock()
+
+	for _, id := range admins {
+		h.sendMessage(api, id, text)
+	}
+}
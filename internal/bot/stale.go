@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/sl"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// staleDepartures is the fallback when fetching departures for siteID fails
+// during an SL outage: the last cached departures that haven't left yet, plus
+// a note saying how old they are. chatID is remembered so RunOutageRecovery
+// can tell it when SL is back.
+func (h *Handler) staleDepartures(chatID int64, siteID string, now time.Time) ([]sl.Departure, string, bool) {
+	since, down := h.slClient.Outage()
+	if !down {
+		return nil, "", false
+	}
+	cached, fetched, ok := h.slClient.StaleDepartures(siteID)
+	if !ok {
+		return nil, "", false
+	}
+
+	var departures []sl.Departure
+	for _, dep := range cached {
+		if dep.Expected.After(now) {
+			departures = append(departures, dep)
+		}
+	}
+	if len(departures) == 0 {
+		return nil, "", false
+	}
+
+	note := fmt.Sprintf("⚠️ *SL API unreachable since %s.* Showing departures fetched at %s; they may have changed.",
+		format.Clock(since), format.Clock(fetched))
+	if h.notificationsEnabled(chatID, store.NotifyServiceAlerts) {
+		h.mu.Lock()
+		h.staleChats[chatID] = siteID
+		h.mu.Unlock()
+		note += " I'll tell you when SL is back."
+	}
+	return departures, note + "\n\n", true
+}
+
+// RunOutageRecovery tells chats that were shown stale departures once SL
+// answers again. While SL is still marked down it probes one of their stops,
+// so recovery is noticed even if nobody else asks for departures.
+// Register it with a scheduler.Scheduler.
+func (h *Handler) RunOutageRecovery(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	h.mu.RLock()
+	var probe string
+	for _, siteID := range h.staleChats {
+		probe = siteID
+		break
+	}
+	h.mu.RUnlock()
+	if probe == "" {
+		return
+	}
+
+	if _, down := h.slClient.Outage(); down {
+		if _, err := h.slClient.GetDepartures(ctx, probe); err != nil {
+			return
+		}
+	}
+
+	h.mu.Lock()
+	chats := h.staleChats
+	h.staleChats = make(map[int64]string)
+	h.mu.Unlock()
+
+	log.Printf("RunOutageRecovery: SL reachable again, notifying %d chats", len(chats))
+	for chatID := range chats {
+		h.sendMessage(api, chatID, "✅ SL is reachable again. Ask again for live departures.")
+	}
+}
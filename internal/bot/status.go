@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/store"
+)
+
+// handleStatus answers "is it me or the bot?": whether SL is answering, how
+// long the bot has been up, and which of the user's pushes are active.
+func (h *Handler) handleStatus(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString("📡 Status\n\n")
+
+	last := h.slClient.LastSuccess()
+	since, down := h.slClient.Outage()
+	switch {
+	case down:
+		fmt.Fprintf(&b, "SL API: ❌ unreachable since %s", format.Clock(since))
+		if !last.IsZero() {
+			fmt.Fprintf(&b, " (last answer %s)", format.Clock(last))
+		}
+		b.WriteString(". Boards may show older times meanwhile.\n")
+	case last.IsZero():
+		b.WriteString("SL API: no calls yet.\n")
+	default:
+		fmt.Fprintf(&b, "SL API: ✅ answering, last at %s.\n", format.Clock(last))
+	}
+	if health := h.slClient.BaseURLHealth(); len(health) > 1 {
+		failing := 0
+		for _, ok := range health {
+			if !ok {
+				failing++
+			}
+		}
+		if failing > 0 {
+			fmt.Fprintf(&b, "%d of %d API endpoints failing, using the others.\n", failing, len(health))
+		}
+	}
+	fmt.Fprintf(&b, "Bot up for %s.\n", formatUptime(now.Sub(h.started)))
+
+	prefs := h.userStore.GetPrefs(userID)
+	h.mu.RLock()
+	alarms := 0
+	for _, a := range h.alarms {
+		if a.userID == userID {
+			alarms++
+		}
+	}
+	h.mu.RUnlock()
+
+	b.WriteString("\nFor you:\n")
+	fmt.Fprintf(&b, "• %d subscription(s)", len(prefs.Subscriptions))
+	if len(prefs.Subscriptions) > 0 && !prefs.Notifications.Enabled(store.NotifyBriefings) {
+		b.WriteString(", paused: briefings are muted in /notifications")
+	}
+	b.WriteString("\n")
+	if d := prefs.Digest; d != nil {
+		fmt.Fprintf(&b, "• Daily digest at %s, %s\n", scheduler.FormatClock(d.At), d.Days)
+	}
+	fmt.Fprintf(&b, "• %d departure alarm(s) set\n", alarms)
+	fmt.Fprintf(&b, "• %d disruption alert(s)", len(prefs.DeviationAlerts))
+	if len(prefs.DeviationAlerts) > 0 && !prefs.Notifications.Enabled(store.NotifyDisruptions) {
+		b.WriteString(", paused: disruption alerts are muted in /notifications")
+	}
+	b.WriteString("\n")
+	h.sendMessage(api, chatID, b.String())
+}
+
+// formatUptime renders d coarsely: "45m", "3h 12m" or "2d 5h".
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, mins := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/scheduler"
+	"github.com/mahmad/slbot/internal/store"
+	"github.com/mahmad/slbot/internal/webhook"
+)
+
+// handleSubscribe parses "/subscribe <stop> <HH:MM-HH:MM> [daily|weekdays|weekends]".
+func (h *Handler) handleSubscribe(ctx context.Context, api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	usage := "❓ Usage: /subscribe <stop> <HH:MM-HH:MM> [daily|weekdays|weekends]"
+
+	fields := strings.Fields(args)
+	days := scheduler.Daily
+	if len(fields) > 0 {
+		if d, err := scheduler.ParseDays(fields[len(fields)-1]); err == nil {
+			days = d
+			fields = fields[:len(fields)-1]
+		}
+	}
+	if len(fields) < 2 {
+		h.sendMessage(api, chatID, usage)
+		return
+	}
+
+	start, end, err := scheduler.ParseWindow(fields[len(fields)-1])
+	if err != nil {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ %v\n%s", err, usage))
+		return
+	}
+	stopQuery := strings.Join(fields[:len(fields)-1], " ")
+
+	site, ok := h.resolveSingleSite(ctx, api, chatID, stopQuery)
+	if !ok {
+		return
+	}
+
+	sub, err := h.userStore.AddSubscription(userID, store.Subscription{
+		ChatID:   chatID,
+		SiteID:   site.SiteID,
+		SiteName: site.Name,
+		Start:    start,
+		End:      end,
+		Days:     string(days),
+	})
+	switch {
+	case errors.Is(err, store.ErrUserLimit):
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ You already have %d subscriptions, the maximum. Remove one first; see /subscriptions.",
+			h.userStore.Limits().SubscriptionsPerUser))
+		return
+	case errors.Is(err, store.ErrGlobalLimit):
+		log.Printf("handleSubscribe: %v", err)
+		h.sendMessage(api, chatID, "❌ The bot is at capacity for subscriptions right now. Try again later.")
+		return
+	case err != nil:
+		log.Printf("handleSubscribe: error saving subscription: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving subscription. Try again later.")
+		return
+	}
+
+	log.Printf("handleSubscribe: user=%d sub=%d site=%d window=%s-%s %s", userID, sub.ID, site.SiteID,
+		scheduler.FormatClock(start), scheduler.FormatClock(end), days)
+	h.sendMessage(api, chatID, fmt.Sprintf("🔔 Subscribed to %s, %s–%s %s.",
+		site.Name, scheduler.FormatClock(start), scheduler.FormatClock(end), days))
+}
+
+// handleListSubscriptions shows the user's subscriptions with the IDs /unsubscribe expects.
+func (h *Handler) handleListSubscriptions(api *tgbotapi.BotAPI, chatID int64, userID int64) {
+	prefs := h.userStore.GetPrefs(userID)
+	subs := prefs.Subscriptions
+	if len(subs) == 0 && len(prefs.DeviationAlerts) == 0 {
+		h.sendMessage(api, chatID, "No subscriptions. Add one with /subscribe <stop> <HH:MM-HH:MM> [weekdays], or /subscribe line <line> for disruption alerts")
+		return
+	}
+
+	var b strings.Builder
+	if len(subs) > 0 {
+		b.WriteString("Your subscriptions:\n")
+		for _, sub := range subs {
+			fmt.Fprintf(&b, "%d. %s, %s–%s %s\n", sub.ID, sub.SiteName,
+				scheduler.FormatClock(sub.Start), scheduler.FormatClock(sub.End), sub.Days)
+		}
+		b.WriteString("\nRemove one with /unsubscribe <number>\n")
+	}
+	if len(prefs.DeviationAlerts) > 0 {
+		b.WriteString("\nDisruption alerts:\n")
+		for _, alert := range prefs.DeviationAlerts {
+			fmt.Fprintf(&b, "• %s (/unsubscribe %s)\n", alertLabel(alert.Line), unsubscribeArg(alert.Line))
+		}
+	}
+	h.sendMessage(api, chatID, strings.TrimSpace(b.String()))
+}
+
+// handleUnsubscribe removes a subscription by ID.
+func (h *Handler) handleUnsubscribe(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	id, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		h.sendMessage(api, chatID, "❓ Usage: /unsubscribe <number from /subscriptions>")
+		return
+	}
+
+	if err := h.userStore.RemoveSubscription(userID, id); err != nil {
+		log.Printf("handleUnsubscribe: %v", err)
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ No subscription number %d.", id))
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("🔕 Subscription %d removed.", id))
+}
+
+// RunSubscriptions pushes departure boards for subscriptions whose window is open
+// and which haven't been sent today. Register it with a scheduler.Scheduler:
+//
+//	sched.Add("subscriptions", func(ctx context.Context, now time.Time) {
+//		handler.RunSubscriptions(ctx, api, now)
+//	})
+func (h *Handler) RunSubscriptions(ctx context.Context, api *tgbotapi.BotAPI, now time.Time) {
+	day := scheduler.DayKey(now)
+	minute := scheduler.MinuteOfDay(now)
+
+	for userID, subs := range h.userStore.AllSubscriptions() {
+		// Banned or no-longer-allowed users keep their data but get no pushes.
+		if h.checkAccess(RequestInfo{UserID: userID, Admin: h.isAdmin(userID)}) != accessAllowed {
+			continue
+		}
+		// Muting briefings pauses every subscription without deleting them.
+		if !h.userStore.GetPrefs(userID).Notifications.Enabled(store.NotifyBriefings) {
+			continue
+		}
+		for _, sub := range subs {
+			if sub.LastSent == day || !scheduler.Days(sub.Days).Includes(now) {
+				continue
+			}
+			if minute < sub.Start || minute > sub.End {
+				continue
+			}
+
+			// Scheduled pushes have no incoming update; give helpers the subscriber's identity.
+			ctx := WithRequestInfo(ctx, RequestInfo{UserID: userID, ChatID: sub.ChatID})
+			// The board lists departures from the subscribed stop, wherever they go.
+			heading := fmt.Sprintf("🚌 Next buses from %s:", escapeMarkdown(sub.SiteName))
+			message, _, err := h.renderBoard(ctx, sub.ChatID, strconv.Itoa(sub.SiteID), heading, "")
+			if err != nil {
+				// Leave LastSent alone so the next tick inside the window retries.
+				log.Printf("RunSubscriptions: user=%d sub=%d: %v", userID, sub.ID, err)
+				continue
+			}
+			h.sendPush(api, userID, sub.ChatID, "briefing", message)
+
+			if err := h.userStore.MarkSubscriptionSent(userID, sub.ID, day); err != nil {
+				log.Printf("RunSubscriptions: error marking sub %d sent: %v", sub.ID, err)
+			}
+			h.emit(webhook.Event{
+				Type:   webhook.EventBriefingSent,
+				UserID: userID,
+				ChatID: sub.ChatID,
+				Data:   map[string]any{"siteId": sub.SiteID, "siteName": sub.SiteName, "subscriptionId": sub.ID},
+			})
+		}
+	}
+}
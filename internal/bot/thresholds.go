@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// minLateAfter and maxLateAfter bound the per-user "late" threshold: below it
+// almost every bus is late, beyond it nothing ever is.
+const (
+	minLateAfter = 30 * time.Second
+	maxLateAfter = 30 * time.Minute
+)
+
+// SetDelayThresholds changes the bot-wide early/late labelling (used at startup).
+// Users can still override the late threshold with /late.
+func (h *Handler) SetDelayThresholds(th format.Thresholds) {
+	h.thresholds = th
+}
+
+// thresholdsFor returns the thresholds to label departures with for userID.
+func (h *Handler) thresholdsFor(userID int64) format.Thresholds {
+	th := h.thresholds
+	if secs := h.userStore.GetPrefs(userID).LateAfterSeconds; secs > 0 {
+		th.Late = time.Duration(secs) * time.Second
+	}
+	return th
+}
+
+// handleLate shows or sets the user's late threshold: "/late 3" or "/late default".
+func (h *Handler) handleLate(api *tgbotapi.BotAPI, chatID int64, userID int64, args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		h.sendMessage(api, chatID, fmt.Sprintf("⏱ Buses count as late after %s. Change with /late <minutes> or /late default.",
+			formatThreshold(h.thresholdsFor(userID).Late)))
+		return
+	}
+
+	var lateAfter time.Duration
+	if args != "default" {
+		mins, err := strconv.ParseFloat(args, 64)
+		lateAfter = time.Duration(mins * float64(time.Minute))
+		if err != nil || lateAfter < minLateAfter || lateAfter > maxLateAfter {
+			h.sendMessage(api, chatID, "❓ Usage: /late <minutes, 0.5 to 30>|default, e.g. /late 2")
+			return
+		}
+	}
+
+	if err := h.userStore.SetLateAfter(userID, lateAfter); err != nil {
+		log.Printf("handleLate: error saving threshold: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	h.sendMessage(api, chatID, fmt.Sprintf("✅ Buses now count as late after %s.", formatThreshold(h.thresholdsFor(userID).Late)))
+}
+
+// formatThreshold renders 30s as "30 seconds" and 2m as "2 min".
+func formatThreshold(d time.Duration) string {
+	if d < time.Minute || d%time.Minute != 0 {
+		return fmt.Sprintf("%d seconds", int(d.Seconds()))
+	}
+	return fmt.Sprintf("%d min", int(d.Minutes()))
+}
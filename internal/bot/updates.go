@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// NextUpdateOffset is the offset to start long polling from after a restart:
+// one past the last update recorded as processed.
+//
+//	u := tgbotapi.NewUpdate(handler.NextUpdateOffset())
+//	for update := range api.GetUpdatesChan(u) {
+//		handler.HandleUpdate(ctx, api, update)
+//	}
+func (h *Handler) NextUpdateOffset() int {
+	if last := h.userStore.LastUpdateID(); last > 0 {
+		return last + 1
+	}
+	return 0
+}
+
+// HandleUpdate dispatches one update and then records its ID in the store, so
+// after a crash Telegram redelivers anything not yet recorded (at least once)
+// and anything already recorded is skipped (deduplicated). For that guarantee
+// updates must be handled in order, one at a time.
+func (h *Handler) HandleUpdate(ctx context.Context, api *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if update.UpdateID <= h.userStore.LastUpdateID() {
+		log.Printf("HandleUpdate: skipping already processed update %d", update.UpdateID)
+		return
+	}
+
+	switch {
+	case update.Message != nil:
+		h.HandleMessage(ctx, api, update.Message)
+	case update.CallbackQuery != nil:
+		h.HandleCallback(ctx, api, update.CallbackQuery)
+	case update.InlineQuery != nil:
+		h.HandleInlineQuery(ctx, api, update.InlineQuery)
+	}
+
+	if err := h.userStore.SetLastUpdateID(update.UpdateID); err != nil {
+		log.Printf("HandleUpdate: error saving update offset %d: %v", update.UpdateID, err)
+	}
+}
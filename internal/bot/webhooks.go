@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/mahmad/slbot/internal/webhook"
+)
+
+// SetWebhooks enables outbound event webhooks (used at startup). nil disables them.
+func (h *Handler) SetWebhooks(d *webhook.Dispatcher) {
+	h.webhooks = d
+}
+
+// emit sends ev to the operator webhooks and the user's personal webhook, if any.
+func (h *Handler) emit(ev webhook.Event) {
+	if h.webhooks == nil {
+		return
+	}
+	h.webhooks.Send(ev, h.userStore.GetPrefs(ev.UserID).WebhookURL)
+}
+
+// handleWebhook sets or clears the user's personal webhook: "/webhook <https-url>" or "/webhook off".
+func (h *Handler) handleWebhook(api *tgbotapi.BotAPI, chatID int64, userID int64, arg string) {
+	if h.webhooks == nil {
+		h.sendMessage(api, chatID, "Webhooks are not enabled on this bot.")
+		return
+	}
+
+	if arg == "off" {
+		if err := h.userStore.SetWebhookURL(userID, ""); err != nil {
+			log.Printf("handleWebhook: error clearing webhook: %v", err)
+			h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+			return
+		}
+		h.sendMessage(api, chatID, "✅ Webhook removed.")
+		return
+	}
+
+	if err := webhook.ValidateURL(arg); err != nil {
+		h.sendMessage(api, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if err := h.userStore.SetWebhookURL(userID, arg); err != nil {
+		log.Printf("handleWebhook: error saving webhook: %v", err)
+		h.sendMessage(api, chatID, "❌ Error saving preference. Try again later.")
+		return
+	}
+	text := "✅ Webhook saved. You'll get a POST for each briefing and alert."
+	if secret := h.webhooks.UserSecret(userID); secret != "" {
+		text += fmt.Sprintf(" Check the X-Slbot-Signature header (HMAC-SHA256 of the body) with your key: `%s`", secret)
+	}
+	h.sendMessage(api, chatID, text)
+}
@@ -0,0 +1,112 @@
+// Package format holds the building blocks every departure or journey renderer
+// shares: delay notation, clock times, countdowns and platforms.
+// Data sources (internal/sl today) compose these into their own layouts, so a
+// delay reads the same everywhere.
+package format
+
+import (
+	"fmt"
+	"math"
+	"time"
+	_ "time/tzdata" // Europe/Stockholm must resolve even without a system zoneinfo
+)
+
+// Thresholds decide when a departure counts as early or late rather than on time.
+type Thresholds struct {
+	Early time.Duration // earlier than schedule by more than this is early
+	Late  time.Duration // later than schedule by more than this is late
+}
+
+// DefaultThresholds allow 30 seconds either way.
+var DefaultThresholds = Thresholds{Early: 30 * time.Second, Late: 30 * time.Second}
+
+// Labels are the words renderers put around numbers. They are the localization
+// hook: a translation supplies another Labels value instead of new formatters.
+type Labels struct {
+	OnTime   string // "on time"
+	Early    string // prefix for early departures, "EARLY"
+	Now      string // countdown for departures leaving now
+	Minutes  string // unit after a minute count, "min"
+	Platform string // "platform"
+	Every    string // headway prefix, "every"
+}
+
+// English is the bot's default (and currently only) language.
+var English = Labels{
+	OnTime:   "on time",
+	Early:    "EARLY",
+	Now:      "now",
+	Minutes:  "min",
+	Platform: "platform",
+	Every:    "every",
+}
+
+// Stockholm is the zone clock times are shown in, whatever zone they were parsed
+// in, and the one schedules run in (see scheduler.Stockholm). The embedded tz
+// database means loading it can't fail.
+var Stockholm = mustLoadLocation("Europe/Stockholm")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// Delay renders how far expected is from scheduled: "+3m", "EARLY −1m", or ""
+// when within th. Callers decide whether to show l.OnTime for "".
+func Delay(l Labels, th Thresholds, scheduled, expected time.Time) string {
+	delta := expected.Sub(scheduled)
+	switch {
+	case delta < -th.Early:
+		return fmt.Sprintf("%s −%dm", l.Early, int((-delta).Minutes()))
+	case delta > th.Late:
+		return fmt.Sprintf("+%dm", int(delta.Minutes()))
+	default:
+		return ""
+	}
+}
+
+// Clock renders t as Stockholm wall-clock time, "15:04".
+func Clock(t time.Time) string {
+	return t.In(Stockholm).Format("15:04")
+}
+
+// Countdown renders the time from now until t: "now" or "4 min".
+func Countdown(l Labels, t, now time.Time) string {
+	if mins := int(t.Sub(now).Minutes()); mins > 0 {
+		return fmt.Sprintf("%d %s", mins, l.Minutes)
+	}
+	return l.Now
+}
+
+// Platform renders ", platform B", or "" when there is no designation.
+func Platform(l Labels, designation string) string {
+	if designation == "" {
+		return ""
+	}
+	return fmt.Sprintf(", %s %s", l.Platform, designation)
+}
+
+// MinuteRange renders a headway-style range: "every 15 min" or "every 7–8 min".
+func MinuteRange(l Labels, lo, hi time.Duration) string {
+	from := int(lo.Round(time.Minute).Minutes())
+	to := int(hi.Round(time.Minute).Minutes())
+	if from < 1 {
+		from = 1
+	}
+	if to <= from {
+		return fmt.Sprintf("%s %d %s", l.Every, from, l.Minutes)
+	}
+	return fmt.Sprintf("%s %d–%d %s", l.Every, from, to, l.Minutes)
+}
+
+// Distance renders a walking distance: "80 m" under a kilometre (rounded to
+// 10 m), otherwise "1.2 km".
+func Distance(meters float64) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%d m", int(math.Round(meters/10)*10))
+	}
+	return fmt.Sprintf("%.1f km", meters/1000)
+}
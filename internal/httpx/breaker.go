@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned (wrapped) for requests refused by CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitBreaker stops calling a host after threshold consecutive failures
+// (transport errors or 5xx) and fails fast with ErrCircuitOpen for cooldown.
+// After that one trial request is let through: success closes the circuit,
+// failure opens it for another cooldown. Hosts are tracked separately, so
+// failing over to another base URL isn't blocked.
+func CircuitBreaker(threshold int, cooldown time.Duration) Middleware {
+	var mu sync.Mutex
+	hosts := make(map[string]*circuit)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			c, ok := hosts[req.URL.Host]
+			if !ok {
+				c = &circuit{}
+				hosts[req.URL.Host] = c
+			}
+			now := time.Now()
+			if now.Before(c.openUntil) || c.trial {
+				mu.Unlock()
+				return nil, fmt.Errorf("%s: %w", req.URL.Host, ErrCircuitOpen)
+			}
+			// Past the cooldown of an open circuit, this request is the trial.
+			c.trial = !c.openUntil.IsZero()
+			mu.Unlock()
+
+			resp, err := next.RoundTrip(req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			c.trial = false
+			if !failed(resp, err) {
+				c.failures = 0
+				c.openUntil = time.Time{}
+				return resp, err
+			}
+			c.failures++
+			if c.failures >= threshold || !c.openUntil.IsZero() {
+				c.openUntil = time.Now().Add(cooldown)
+			}
+			return resp, err
+		})
+	}
+}
+
+type circuit struct {
+	failures  int       // consecutive failures
+	openUntil time.Time // zero while closed
+	trial     bool      // a half-open trial request is in flight
+}
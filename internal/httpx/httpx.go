@@ -0,0 +1,45 @@
+// Package httpx builds HTTP transports from small, reusable middlewares:
+// logging, metrics, retries, rate limiting, default headers and a circuit
+// breaker. Clients compose the ones they need once, at construction:
+//
+//	rt := httpx.Chain(http.DefaultTransport,
+//		httpx.Logging(),
+//		httpx.Retry(2, 200*time.Millisecond),
+//		httpx.CircuitBreaker(5, 30*time.Second),
+//	)
+//	client := &http.Client{Transport: rt}
+//
+// so the cross-cutting behaviour lives here rather than in each API client.
+package httpx
+
+import "net/http"
+
+// Middleware wraps a RoundTripper with extra behaviour.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper, like http.HandlerFunc.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps base in mws. The first middleware is the outermost: it sees the
+// request first and the response last. A nil base means http.DefaultTransport.
+func Chain(base http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// failed reports whether a round trip counts as a server-side failure:
+// a transport error or a 5xx response.
+func failed(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
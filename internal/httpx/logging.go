@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mahmad/slbot/internal/logging"
+)
+
+// Logging logs every round trip at debug level with its method, host, path,
+// status and latency. Query strings are left out since they may carry keys.
+func Logging() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			attrs := []any{
+				"method", req.Method,
+				"host", req.URL.Host,
+				"path", req.URL.Path,
+				logging.KeyLatencyMs, time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				attrs = append(attrs, "error", err.Error())
+			} else {
+				attrs = append(attrs, "status", resp.StatusCode)
+			}
+			slog.Debug("http round trip", attrs...)
+			return resp, err
+		})
+	}
+}
+
+// Header sets a header on every request that doesn't already have it, e.g. an
+// API key or User-Agent. The caller's request is not modified.
+func Header(key, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(key) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(key, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package httpx
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics counts round trips through its Middleware. The zero value is ready to use.
+type Metrics struct {
+	requests  atomic.Int64
+	errors    atomic.Int64 // transport errors
+	status4xx atomic.Int64
+	status5xx atomic.Int64
+	latencyNs atomic.Int64
+}
+
+// MetricsSnapshot is a point-in-time copy of Metrics.
+type MetricsSnapshot struct {
+	Requests  int64
+	Errors    int64
+	Status4xx int64
+	Status5xx int64
+	Latency   time.Duration // total across requests
+}
+
+// Middleware records each round trip in m.
+func (m *Metrics) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			m.requests.Add(1)
+			m.latencyNs.Add(int64(time.Since(start)))
+			switch {
+			case err != nil:
+				m.errors.Add(1)
+			case resp.StatusCode >= 500:
+				m.status5xx.Add(1)
+			case resp.StatusCode >= 400:
+				m.status4xx.Add(1)
+			}
+			return resp, err
+		})
+	}
+}
+
+// Snapshot returns the counters so far.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Requests:  m.requests.Load(),
+		Errors:    m.errors.Load(),
+		Status4xx: m.status4xx.Load(),
+		Status5xx: m.status5xx.Load(),
+		Latency:   time.Duration(m.latencyNs.Load()),
+	}
+}
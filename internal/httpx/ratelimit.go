@@ -0,0 +1,53 @@
+package httpx
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit spaces requests with a token bucket holding up to burst tokens and
+// refilled at perSecond. A request waits for a token (or for its context to be
+// done) rather than being refused.
+func RateLimit(perSecond float64, burst int) Middleware {
+	bucket := &tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for {
+				wait := bucket.take(time.Now())
+				if wait == 0 {
+					break
+				}
+				select {
+				case <-time.After(wait):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take consumes a token and returns 0, or returns how long until one is available.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,42 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Retry retries GET and HEAD requests up to retries more times after a
+// transport error, a 5xx or a 429, waiting backoff, then twice that, and so on.
+// Other methods pass straight through, since they may not be safe to repeat.
+// Waiting stops early when the request's context is done.
+func Retry(retries int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next.RoundTrip(req)
+			}
+
+			wait := backoff
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				retryable := failed(resp, err) || resp.StatusCode == http.StatusTooManyRequests
+				if !retryable || attempt >= retries {
+					return resp, err
+				}
+				if resp != nil {
+					// Drain so the connection can be reused for the next attempt.
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				select {
+				case <-time.After(wait):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+				wait *= 2
+			}
+		})
+	}
+}
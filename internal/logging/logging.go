@@ -0,0 +1,50 @@
+// Package logging configures process-wide log output. Most code logs with the
+// standard log package; a few places add structured fields with log/slog.
+// Both end up in the same handler once Setup has run.
+package logging
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// Field names shared by structured log lines, so aggregators (Loki, ELK) can
+// index the same key everywhere.
+const (
+	KeyUserID    = "user_id"
+	KeyCommand   = "cmd"
+	KeyLatencyMs = "latency_ms"
+	KeyEndpoint  = "endpoint"
+)
+
+// Setup installs the default logger according to LOG_FORMAT:
+//
+//	LOG_FORMAT=text (default)  the standard "2006/01/02 15:04:05 message" lines
+//	LOG_FORMAT=json            one JSON object per line with ts, level and msg
+//	LOG_LEVEL=debug            in JSON mode, also log each SL request with its latency
+//
+// In JSON mode plain log.Printf calls become {"level":"INFO","msg":...} lines too.
+func Setup(w io.Writer) {
+	if os.Getenv("LOG_FORMAT") != "json" {
+		log.SetOutput(w)
+		return
+	}
+
+	level := slog.LevelInfo
+	if os.Getenv("LOG_LEVEL") == "debug" {
+		level = slog.LevelDebug
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				a.Key = "ts"
+			}
+			return a
+		},
+	})
+	slog.SetDefault(slog.New(handler))
+}
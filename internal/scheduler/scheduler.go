@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// Job is a unit of periodic work. It receives the tick time in the scheduler's location
+// and decides for itself whether anything is due.
+type Job func(ctx context.Context, now time.Time)
+
+// Scheduler runs registered jobs on a fixed tick.
+// Jobs run sequentially within a tick, so a slow job delays the others;
+// jobs that call SL should use short per-call timeouts.
+type Scheduler struct {
+	interval time.Duration
+	location *time.Location
+
+	mu   sync.Mutex
+	jobs []namedJob
+}
+
+type namedJob struct {
+	name string
+	run  Job
+}
+
+// New creates a Scheduler that ticks every interval, reporting times in loc.
+func New(interval time.Duration, loc *time.Location) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		location: loc,
+	}
+}
+
+// Add registers a job. It is safe to call while the scheduler is running.
+func (s *Scheduler) Add(name string, job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, namedJob{name: name, run: job})
+}
+
+// Run ticks until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			s.tick(ctx, t.In(s.location))
+		}
+	}
+}
+
+// tick runs every job once, recovering from panics so one bad job can't stop the others.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	jobs := append([]namedJob(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("scheduler: job %s panicked: %v", job.name, r)
+				}
+			}()
+			job.run(ctx, now)
+		}()
+	}
+}
+
+// Stockholm returns the Europe/Stockholm location SL timetables use; it is
+// format.Stockholm, so schedules and displayed times always agree.
+func Stockholm() *time.Location {
+	return format.Stockholm
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Days names the set of weekdays a schedule applies to.
+type Days string
+
+const (
+	Daily    Days = "daily"
+	Weekdays Days = "weekdays"
+	Weekends Days = "weekends"
+)
+
+// ParseDays accepts "daily", "weekdays" or "weekends" (case-insensitive).
+func ParseDays(s string) (Days, error) {
+	switch d := Days(strings.ToLower(strings.TrimSpace(s))); d {
+	case Daily, Weekdays, Weekends:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown days %q (want daily, weekdays or weekends)", s)
+	}
+}
+
+// Includes reports whether t falls on one of the days.
+func (d Days) Includes(t time.Time) bool {
+	weekend := t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	switch d {
+	case Weekdays:
+		return !weekend
+	case Weekends:
+		return weekend
+	default:
+		return true
+	}
+}
+
+// ParseClock parses "HH:MM" into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock renders minutes after midnight as "HH:MM".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseWindow parses "HH:MM-HH:MM" into start/end minutes after midnight.
+// Windows must not cross midnight.
+func ParseWindow(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window %q (want HH:MM-HH:MM)", s)
+	}
+	if start, err = ParseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = ParseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("window %q ends before it starts", s)
+	}
+	return start, end, nil
+}
+
+// MinuteOfDay returns t's minutes after midnight.
+func MinuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// DayKey returns a stable per-day key ("2006-01-02") for "once per day" bookkeeping.
+func DayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,104 @@
+package sl
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed aliases.txt
+var builtinAliases string
+
+// Aliases maps lowercased stop abbreviations ("cst", "oden") to the name to
+// search for instead ("Centralen", "Odenplan").
+type Aliases map[string]string
+
+// activeAliases is the table Match and FuzzyMatch expand queries with.
+var activeAliases atomic.Pointer[Aliases]
+
+// init loads the built-in table, extended with the file at SL_ALIASES_FILE if
+// set (see LoadAliases). A file that can't be read is logged and skipped.
+func init() {
+	a, err := parseAliases(strings.NewReader(builtinAliases))
+	if err != nil {
+		panic(fmt.Sprintf("sl: built-in aliases: %v", err))
+	}
+	if path := os.Getenv("SL_ALIASES_FILE"); path != "" {
+		if extended, err := LoadAliases(path); err != nil {
+			log.Printf("sl: SL_ALIASES_FILE: %v; using the built-in aliases", err)
+		} else {
+			a = extended
+		}
+	}
+	activeAliases.Store(&a)
+}
+
+// DefaultAliases returns a copy of the built-in abbreviation table.
+func DefaultAliases() Aliases {
+	a, _ := parseAliases(strings.NewReader(builtinAliases)) // validated in init
+	return a
+}
+
+// LoadAliases returns the built-in table extended with the entries in the file
+// at path, which uses the same "alias = name" format; file entries win.
+func LoadAliases(path string) (Aliases, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open aliases: %w", err)
+	}
+	defer f.Close()
+
+	extra, err := parseAliases(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	a := DefaultAliases()
+	for alias, name := range extra {
+		a[alias] = name
+	}
+	return a, nil
+}
+
+// SetAliases replaces the table used to expand search queries. SL_ALIASES_FILE
+// covers the usual case; use it to load a table some other way.
+func SetAliases(a Aliases) {
+	activeAliases.Store(&a)
+}
+
+// expandAlias returns the name an abbreviated query stands for, or query itself.
+func expandAlias(query string) string {
+	a := *activeAliases.Load()
+	if name, ok := a[strings.Join(strings.Fields(strings.ToLower(query)), " ")]; ok {
+		return name
+	}
+	return query
+}
+
+// parseAliases reads "alias = name" lines, skipping blanks and # comments.
+func parseAliases(r io.Reader) (Aliases, error) {
+	a := make(Aliases)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		alias, name, ok := strings.Cut(text, "=")
+		alias = strings.Join(strings.Fields(strings.ToLower(alias)), " ")
+		name = strings.TrimSpace(name)
+		if !ok || alias == "" || name == "" {
+			return nil, fmt.Errorf("line %d: want \"alias = name\", got %q", line, text)
+		}
+		a[alias] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read aliases: %w", err)
+	}
+	return a, nil
+}
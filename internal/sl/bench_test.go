@@ -0,0 +1,147 @@
+package sl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// benchSites returns n made-up sites with SL-like names, about the size of
+// the real sites list when n is 10000.
+func benchSites(n int) []Site {
+	prefixes := []string{"Frösunda", "Solna", "Sollentuna", "Kista", "Hagsätra", "Tensta", "Skärholmen", "Farsta",
+		"Gullmarsplan", "Slussen", "Odenplan", "Fridhemsplan", "Liljeholmen", "Danderyd", "Mörby", "Täby",
+		"Jakobsberg", "Huddinge", "Flemingsberg", "Södertälje"}
+	suffixes := []string{"centrum", "torg", "station", "norra", "södra", "skola", "kyrka", "gård", "strand", "backe"}
+
+	sites := make([]Site, n)
+	for i := range sites {
+		name := fmt.Sprintf("%s %s", prefixes[i%len(prefixes)], suffixes[(i/len(prefixes))%len(suffixes)])
+		if i >= len(prefixes)*len(suffixes) {
+			name = fmt.Sprintf("%s %d", name, i/(len(prefixes)*len(suffixes)))
+		}
+		sites[i] = Site{Name: name, SiteID: 1000 + i, Type: "STOP_AREA"}
+	}
+	return sites
+}
+
+// benchQueries are typical things users type, from a prefix to a full name.
+var benchQueries = []string{"frö", "solna c", "gullmarsplan", "kista centrum 12", "cst", "nowhere"}
+
+func BenchmarkFuzzyMatch(b *testing.B) {
+	sites := benchSites(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FuzzyMatch(benchQueries[i%len(benchQueries)], sites, 5)
+	}
+}
+
+func BenchmarkSiteIndexMatch(b *testing.B) {
+	idx := NewSiteIndex(benchSites(10000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Match(benchQueries[i%len(benchQueries)], 5)
+	}
+}
+
+func BenchmarkNewSiteIndex(b *testing.B) {
+	sites := benchSites(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewSiteIndex(sites)
+	}
+}
+
+// benchDepartures returns a departures response with n departures, with
+// offset-less timestamps as SL sends them.
+func benchDepartures(b *testing.B, n int) []byte {
+	b.Helper()
+	var resp struct {
+		Departures []map[string]any `json:"departures"`
+	}
+	start := time.Date(2025, 12, 27, 7, 0, 0, 0, format.Stockholm)
+	for i := 0; i < n; i++ {
+		at := start.Add(time.Duration(i) * 4 * time.Minute)
+		resp.Departures = append(resp.Departures, map[string]any{
+			"scheduled":     at.Format("2006-01-02T15:04:05"),
+			"expected":      at.Add(30 * time.Second).Format("2006-01-02T15:04:05"),
+			"line":          fmt.Sprint(1 + i%5),
+			"direction":     "Skärholmen",
+			"displayText":   "3 min",
+			"stopArea":      map[string]any{"name": "Storgatan", "siteId": 3484},
+			"deviations":    []any{},
+			"directionCode": 1,
+			"stopPoint":     map[string]any{"id": 34841, "name": "Storgatan", "designation": "A"},
+			"journey":       map[string]any{"id": 202512270048400 + i, "state": "NORMALPROGRESS"},
+		})
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeDepartures(b *testing.B) {
+	data := benchDepartures(b, 50)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp DeparturesResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeDeparturesFixture(b *testing.B) {
+	data, err := os.ReadFile("fixtures/3484.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp DeparturesResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeSites(b *testing.B) {
+	data, err := json.Marshal(SitesResponse{Sites: benchSites(10000)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp SitesResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormatDepartures(b *testing.B) {
+	var resp DeparturesResponse
+	if err := json.Unmarshal(benchDepartures(b, 50), &resp); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FormatDepartures(resp.Departures, 10, format.DefaultThresholds)
+	}
+}
@@ -0,0 +1,76 @@
+package sl
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// LineGroup is the departures of one line in one direction, in response order.
+type LineGroup struct {
+	Line       string
+	Direction  string
+	Departures []Departure
+}
+
+// GroupByLine splits departures into one group per line and direction.
+// Groups are ordered by their first departure, as SL sorts responses by time.
+func GroupByLine(departures []Departure) []LineGroup {
+	type key struct{ line, direction string }
+
+	index := make(map[key]int)
+	var groups []LineGroup
+	for _, dep := range departures {
+		k := key{dep.Line, dep.Direction}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, LineGroup{Line: dep.Line, Direction: dep.Direction})
+		}
+		groups[i].Departures = append(groups[i].Departures, dep)
+	}
+	return groups
+}
+
+// FormatLineGroup renders a group on one line with its next count departures,
+// e.g. "*26* → Gullmarsplan: 07:43, 07:51 (+2m), 08:01".
+func FormatLineGroup(g LineGroup, count int, th format.Thresholds) string {
+	l := format.English
+
+	times := make([]string, 0, count)
+	for _, dep := range g.Departures[:min(count, len(g.Departures))] {
+		t := format.Clock(dep.Expected)
+		if status := format.Delay(l, th, dep.Scheduled, dep.Expected); status != "" {
+			t += " (" + status + ")"
+		}
+		times = append(times, t)
+	}
+	return "*" + g.Line + "* → " + g.Direction + ": " + strings.Join(times, ", ")
+}
+
+// FormatLineGroupRelative renders a group with minutes until its next count
+// departures, e.g. "*515* → Odenplan: now, 11 (+2m), 19 min".
+func FormatLineGroupRelative(g LineGroup, count int, now time.Time, th format.Thresholds) string {
+	l := format.English
+
+	shown := g.Departures[:min(count, len(g.Departures))]
+	times := make([]string, 0, len(shown))
+	for i, dep := range shown {
+		t := l.Now
+		if mins := int(dep.Expected.Sub(now).Minutes()); mins > 0 {
+			t = strconv.Itoa(mins)
+			// The unit goes once, after the last count: "3, 11, 19 min".
+			if i == len(shown)-1 {
+				t += " " + l.Minutes
+			}
+		}
+		if status := format.Delay(l, th, dep.Scheduled, dep.Expected); status != "" {
+			t += " (" + status + ")"
+		}
+		times = append(times, t)
+	}
+	return "*" + g.Line + "* → " + g.Direction + ": " + strings.Join(times, ", ")
+}
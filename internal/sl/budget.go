@@ -0,0 +1,163 @@
+package sl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Endpoint names used for request accounting.
+const (
+	EndpointDepartures = "departures"
+	EndpointSites      = "sites"
+)
+
+// degradeAt is the fraction of a soft limit after which the client starts degrading.
+const degradeAt = 0.8
+
+// EndpointUsage is a snapshot of upstream calls made to one endpoint.
+type EndpointUsage struct {
+	Endpoint  string
+	Today     int64 // calls since local midnight
+	Total     int64 // calls since the client was created
+	SoftLimit int64 // per-day soft limit, 0 if unlimited
+	Degraded  bool  // true once Today reaches degradeAt of SoftLimit
+}
+
+// budget tracks per-endpoint upstream calls against daily soft limits.
+// It is only an accounting aid: calls are never refused, but once an endpoint is
+// close to its limit the client serves cached data for longer.
+type budget struct {
+	mu     sync.Mutex
+	day    string // YYYY-MM-DD of the current counting window
+	today  map[string]int64
+	total  map[string]int64
+	limits map[string]int64
+}
+
+func newBudget() *budget {
+	return &budget{
+		today:  make(map[string]int64),
+		total:  make(map[string]int64),
+		limits: make(map[string]int64),
+	}
+}
+
+// record counts one upstream call to endpoint.
+func (b *budget) record(endpoint string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(now)
+	b.today[endpoint]++
+	b.total[endpoint]++
+}
+
+// degraded reports whether endpoint has used up most of today's soft limit.
+func (b *budget) degraded(endpoint string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(now)
+	return b.degradedLocked(endpoint)
+}
+
+func (b *budget) degradedLocked(endpoint string) bool {
+	limit := b.limits[endpoint]
+	return limit > 0 && float64(b.today[endpoint]) >= degradeAt*float64(limit)
+}
+
+// rollLocked resets the daily counters when the date changes.
+func (b *budget) rollLocked(now time.Time) {
+	day := now.Format("2006-01-02")
+	if day != b.day {
+		b.day = day
+		b.today = make(map[string]int64)
+	}
+}
+
+// SetSoftLimit configures a per-day soft limit for an endpoint (0 removes it).
+func (c *Client) SetSoftLimit(endpoint string, perDay int64) {
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+
+	if perDay <= 0 {
+		delete(c.budget.limits, endpoint)
+		return
+	}
+	c.budget.limits[endpoint] = perDay
+}
+
+// Usage returns per-endpoint call counts, sorted by endpoint name.
+func (c *Client) Usage() []EndpointUsage {
+	b := c.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollLocked(time.Now())
+
+	seen := make(map[string]bool)
+	for e := range b.total {
+		seen[e] = true
+	}
+	for e := range b.limits {
+		seen[e] = true
+	}
+
+	usage := make([]EndpointUsage, 0, len(seen))
+	for e := range seen {
+		usage = append(usage, EndpointUsage{
+			Endpoint:  e,
+			Today:     b.today[e],
+			Total:     b.total[e],
+			SoftLimit: b.limits[e],
+			Degraded:  b.degradedLocked(e),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Endpoint < usage[j].Endpoint })
+
+	return usage
+}
+
+// WriteMetrics writes upstream call counters in the Prometheus text format;
+// MetricsHandler serves them over HTTP.
+func (c *Client) WriteMetrics(w io.Writer) error {
+	usage := c.Usage()
+
+	if _, err := fmt.Fprintln(w, "# TYPE sl_upstream_calls_total counter"); err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if _, err := fmt.Fprintf(w, "sl_upstream_calls_total{endpoint=%q} %d\n", u.Endpoint, u.Total); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, "# TYPE sl_upstream_calls_today gauge"); err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if _, err := fmt.Fprintf(w, "sl_upstream_calls_today{endpoint=%q} %d\n", u.Endpoint, u.Today); err != nil {
+			return err
+		}
+	}
+
+	stats := c.ConnStats()
+	_, err := fmt.Fprintf(w, "# TYPE sl_http_conns_total counter\nsl_http_conns_total{reused=\"true\"} %d\nsl_http_conns_total{reused=\"false\"} %d\n",
+		stats.Reused, stats.New)
+	return err
+}
+
+// MetricsHandler serves WriteMetrics for a host process to mount:
+//
+//	http.Handle("/metrics", slClient.MetricsHandler())
+func (c *Client) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		// A write error means the scraper went away; there's no one left to tell.
+		_ = c.WriteMetrics(w)
+	})
+}
@@ -0,0 +1,77 @@
+package sl
+
+import (
+	"sync"
+	"time"
+)
+
+// departuresCache holds recent departures per site.
+// With the default TTL of zero it is only consulted once an endpoint is degraded.
+type departuresCache struct {
+	mu          sync.Mutex
+	entries     map[string]cachedDepartures
+	ttl         time.Duration // normal freshness window
+	degradedTTL time.Duration // freshness window while close to the soft limit
+}
+
+type cachedDepartures struct {
+	departures []Departure
+	fetched    time.Time
+}
+
+func newDeparturesCache() *departuresCache {
+	return &departuresCache{
+		entries:     make(map[string]cachedDepartures),
+		degradedTTL: 2 * time.Minute,
+	}
+}
+
+// get returns cached departures for siteID if they are younger than the applicable TTL.
+func (dc *departuresCache) get(siteID string, now time.Time, degraded bool) ([]Departure, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	ttl := dc.ttl
+	if degraded && dc.degradedTTL > ttl {
+		ttl = dc.degradedTTL
+	}
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	entry, ok := dc.entries[siteID]
+	if !ok || now.Sub(entry.fetched) > ttl {
+		return nil, false
+	}
+	return entry.departures, true
+}
+
+// put records a fresh result for siteID.
+func (dc *departuresCache) put(siteID string, departures []Departure, now time.Time) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.entries[siteID] = cachedDepartures{departures: departures, fetched: now}
+}
+
+// StaleDepartures returns the last departures fetched for siteID however old
+// they are, with the time they were fetched, for use while SL is unreachable
+// (see Outage). Callers should make the age clear to users.
+func (c *Client) StaleDepartures(siteID string) ([]Departure, time.Time, bool) {
+	c.depCache.mu.Lock()
+	defer c.depCache.mu.Unlock()
+
+	entry, ok := c.depCache.entries[siteID]
+	return entry.departures, entry.fetched, ok
+}
+
+// SetDeparturesCacheTTL configures how long departures are reused.
+// normal applies at all times (0 disables caching); degraded applies once the
+// departures endpoint nears its soft limit (see SetSoftLimit).
+func (c *Client) SetDeparturesCacheTTL(normal, degraded time.Duration) {
+	c.depCache.mu.Lock()
+	defer c.depCache.mu.Unlock()
+
+	c.depCache.ttl = normal
+	c.depCache.degradedTTL = degraded
+}
@@ -0,0 +1,96 @@
+package sl
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Faults injects failures into dry-run responses so retries, fallbacks and
+// deadlines can be exercised without SL. They are injected as HTTP responses
+// by fixtureTransport, under the httpx middlewares (see NewDryRunClient), so
+// they take the same path as real failures: a 500 is retried, counts towards
+// the circuit breaker, marks the base URL down and, with no other to try,
+// starts an outage, which is when stale cached departures are served. Rates
+// are probabilities from 0 to 1 and are rolled independently per request; the
+// zero value injects nothing.
+type Faults struct {
+	ErrorRate    float64       // answer with status 500
+	TruncateRate float64       // cut departures JSON short so decoding fails
+	EmptyRate    float64       // answer with no departures
+	Latency      time.Duration // added to every call, interrupted by ctx
+	Jitter       time.Duration // up to this much extra random latency
+}
+
+// FaultsFromEnv reads fault injection settings for dry-run mode.
+// Invalid values are ignored.
+//
+//	SL_CHAOS_ERROR_RATE=0.1      10% of calls fail with status 500
+//	SL_CHAOS_TRUNCATE_RATE=0.05  5% of calls get truncated JSON
+//	SL_CHAOS_EMPTY_RATE=0.1      10% of calls return no departures
+//	SL_CHAOS_LATENCY=2s          fixed delay per call
+//	SL_CHAOS_JITTER=1s           random extra delay per call
+func FaultsFromEnv() Faults {
+	var f Faults
+	envRate("SL_CHAOS_ERROR_RATE", &f.ErrorRate)
+	envRate("SL_CHAOS_TRUNCATE_RATE", &f.TruncateRate)
+	envRate("SL_CHAOS_EMPTY_RATE", &f.EmptyRate)
+	envDuration("SL_CHAOS_LATENCY", &f.Latency)
+	envDuration("SL_CHAOS_JITTER", &f.Jitter)
+	return f
+}
+
+// SetFaults enables fault injection. It only affects dry-run mode.
+// Call it before the client is used.
+func (c *Client) SetFaults(f Faults) {
+	c.faults = f
+}
+
+// injectDelay sleeps for the configured latency, returning early with ctx's error.
+// The error is returned as is: it fails the round trip like a network error.
+func (f Faults) injectDelay(ctx context.Context) error {
+	delay := f.Latency
+	if f.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(f.Jitter)))
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// corrupt empties or truncates a departures body if rolled.
+func (f Faults) corrupt(body []byte) []byte {
+	if roll(f.EmptyRate) {
+		empty, _ := json.Marshal(DeparturesResponse{Departures: []Departure{}})
+		return empty
+	}
+	if roll(f.TruncateRate) && len(body) > 1 {
+		return body[:rand.Intn(len(body)-1)+1]
+	}
+	return body
+}
+
+func roll(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// envRate overwrites *dst with the probability in env var name, if it is within [0, 1].
+func envRate(name string, dst *float64) {
+	if v := os.Getenv(name); v != "" {
+		if r, err := strconv.ParseFloat(v, 64); err == nil && r >= 0 && r <= 1 {
+			*dst = r
+		}
+	}
+}
@@ -4,44 +4,92 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"io/fs"
 	"net/http"
-	"os"
 	"strings"
 	"time"
+
+	"github.com/mahmad/slbot/internal/format"
 )
 
 // Client wraps the SL Transport API.
 // In Go, we use simple structs to bundle related data and methods.
 // This is called a "receiver type" or "struct with methods".
 type Client struct {
-	httpClient *http.Client
-	dryRun     bool
-	baseURL    string
+	httpClient *http.Client // serves fixtures in dry-run mode, see fixtureTransport
+	bases      *baseURLs    // API roots in failover order, see SetBaseURLs
+	conns      connCounters // connection reuse metrics, see ConnStats
+	budget     *budget      // per-endpoint upstream call accounting
+	depCache   *departuresCache
+	faults     Faults        // dry-run fault injection, see SetFaults
+	fixtures   fs.FS         // dry-run responses, see SetFixtures
+	forecast   time.Duration // departures window, 0 for the API default; see SetForecast
 }
 
 // NewClient is a constructor.
 // Go doesn't have explicit constructors, but returning a named type from a New* function is the convention.
 // This ensures the Client is always properly initialized.
+// In dry-run mode httpClient is not used; the client is built as by
+// NewDryRunClient with TransportConfigFromEnv, keeping httpClient's timeout.
 func NewClient(httpClient *http.Client, dryRun bool) *Client {
+	if dryRun {
+		cfg := TransportConfigFromEnv()
+		if httpClient != nil && httpClient.Timeout > 0 {
+			cfg.RequestTimeout = httpClient.Timeout
+		}
+		return NewDryRunClient(cfg)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return newClient(httpClient)
+}
+
+// NewDryRunClient returns a client whose requests never leave the process: they
+// are answered from fixtures (see SetFixtures) by a transport wrapped in cfg's
+// middlewares, so injected faults (see SetFaults) go through retries and the
+// circuit breaker, and everything else (failover, caching, outage tracking)
+// works as it does against SL. cfg's network settings are unused.
+func NewDryRunClient(cfg TransportConfig) *Client {
+	c := newClient(nil)
+	cfg.Base = fixtureTransport{c}
+	c.httpClient = NewHTTPClient(cfg)
+	return c
+}
+
+func newClient(httpClient *http.Client) *Client {
 	return &Client{
 		httpClient: httpClient,
-		dryRun:     dryRun,
-		baseURL:    "https://transport.integration.sl.se/v1",
+		bases:      newBaseURLs("https://transport.integration.sl.se/v1"),
+		budget:     newBudget(),
+		depCache:   newDeparturesCache(),
+		fixtures:   defaultFixtures(),
 	}
 }
 
+// SetBaseURL points the client at a different API root, e.g. an slproxy instance
+// ("http://localhost:8090/v1"). Call it before the client is used.
+// Use SetBaseURLs to configure fallbacks.
+func (c *Client) SetBaseURL(baseURL string) {
+	c.SetBaseURLs(baseURL)
+}
+
 // Departure represents a single bus departure.
 // struct tags like `json:"expected"` tell the JSON decoder which JSON field maps to this struct field.
 // Lowercase fields are unexported (private); PascalCase are exported (public).
 type Departure struct {
-	Scheduled   time.Time `json:"scheduled"`
-	Expected    time.Time `json:"expected"`
-	Line        string    `json:"line"`
-	Direction   string    `json:"direction"`
-	DisplayText string    `json:"displayText"`
-	StopArea    StopArea  `json:"stopArea"`
-	Deviations  []string  `json:"deviations"`
+	Scheduled   time.Time   `json:"scheduled"`
+	Expected    time.Time   `json:"expected"`
+	Line        string      `json:"line"`
+	Direction   string      `json:"direction"`
+	DisplayText string      `json:"displayText"`
+	StopArea    StopArea    `json:"stopArea"`
+	Deviations  []Deviation `json:"deviations"`
+
+	// DirectionCode is SL's 1/2 direction of travel along the line; 0 if unknown.
+	DirectionCode int       `json:"directionCode"`
+	StopPoint     StopPoint `json:"stopPoint"` // the platform or pole within StopArea
+	Journey       Journey   `json:"journey"`
 }
 
 // StopArea holds minimal stop metadata.
@@ -50,11 +98,26 @@ type StopArea struct {
 	SiteID int    `json:"siteId"`
 }
 
+// StopPoint is one boarding point within a stop area, e.g. platform "B".
+type StopPoint struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Designation string `json:"designation"` // platform/pole letter or number, may be empty
+}
+
+// Journey identifies the vehicle trip a departure belongs to.
+type Journey struct {
+	ID    int64  `json:"id"`    // stable for the trip across stops; 0 if unknown
+	State string `json:"state"` // e.g. "NORMALPROGRESS", "CANCELLED"
+}
+
 // Site represents a bus stop or station.
 type Site struct {
-	Name   string `json:"name"`
-	SiteID int    `json:"siteId"`
-	Type   string `json:"type"` // "STATION", "STOP_AREA", etc.
+	Name   string  `json:"name"`
+	SiteID int     `json:"siteId"`
+	Type   string  `json:"type"` // "STATION", "STOP_AREA", etc.
+	Lat    float64 `json:"lat"`  // WGS84; 0 if SL has no position
+	Lon    float64 `json:"lon"`
 }
 
 // DeparturesResponse is the full response from SL's /departures endpoint.
@@ -67,39 +130,30 @@ type SitesResponse struct {
 	Sites []Site `json:"sites"`
 }
 
-// GetDepartures fetches departures for a site.
-// It respects the context timeout and implements dry-run mode.
+// GetDepartures fetches departures for a site, within the window set by SetForecast.
+// It respects the context timeout.
 func (c *Client) GetDepartures(ctx context.Context, siteID string) ([]Departure, error) {
-	if c.dryRun {
-		return c.loadFixture(siteID)
-	}
-
-	url := fmt.Sprintf("%s/sites/%s/departures", c.baseURL, siteID)
-
-	// http.NewRequestWithContext attaches the context to the HTTP request.
-	// If the context is cancelled (e.g., timeout), the request will be interrupted.
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
+	return c.getDepartures(ctx, siteID, c.forecast)
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
+// getDepartures fetches departures for a site up to window ahead (0 for the API default).
+func (c *Client) getDepartures(ctx context.Context, siteID string, window time.Duration) ([]Departure, error) {
+	// Serve from cache when fresh enough; near the soft limit "fresh enough" gets longer.
+	// Each window is cached separately, keyed by the request path.
+	now := time.Now()
+	path := departuresPath(siteID, window)
+	cacheKey := siteID
+	if window != c.forecast {
+		cacheKey = path
 	}
-	// Always close response body to avoid leaking connections.
-	// defer ensures this happens even if we return early on error.
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	if cached, ok := c.depCache.get(cacheKey, now, c.budget.degraded(EndpointDepartures, now)); ok {
+		return cached, nil
 	}
 
-	// io.ReadAll reads the entire response into memory.
-	// For small responses (like SL departures), this is fine.
-	body, err := io.ReadAll(resp.Body)
+	// get tries each configured base URL in turn; see failover.go.
+	body, err := c.get(ctx, EndpointDepartures, path)
 	if err != nil {
-		return nil, fmt.Errorf("read body: %w", err)
+		return nil, err
 	}
 
 	// json.Unmarshal decodes JSON bytes into a Go struct.
@@ -109,42 +163,16 @@ func (c *Client) GetDepartures(ctx context.Context, siteID string) ([]Departure,
 		return nil, fmt.Errorf("unmarshal json: %w", err)
 	}
 
+	c.depCache.put(cacheKey, respData.Departures, now)
 	return respData.Departures, nil
 }
 
 // GetSites fetches all SL sites (bus stops, stations).
 // This is called once for fuzzy matching; the result is cached by the handler.
 func (c *Client) GetSites(ctx context.Context) ([]Site, error) {
-	if c.dryRun {
-		// For dry-run, return a hardcoded list of common sites.
-		return []Site{
-			{Name: "Storgatan", SiteID: 3484, Type: "STOP_AREA"},
-			{Name: "Frösunda torg", SiteID: 3455, Type: "STOP_AREA"},
-			{Name: "Solna centrum norra", SiteID: 3472, Type: "STOP_AREA"},
-			{Name: "Solna centrum", SiteID: 9305, Type: "STOP_AREA"},
-		}, nil
-	}
-
-	url := fmt.Sprintf("%s/sites", c.baseURL)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("do request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(ctx, EndpointSites, "/sites")
 	if err != nil {
-		return nil, fmt.Errorf("read body: %w", err)
+		return nil, err
 	}
 
 	var respData SitesResponse
@@ -156,10 +184,11 @@ func (c *Client) GetSites(ctx context.Context) ([]Site, error) {
 }
 
 // FuzzyMatch finds the top `count` sites matching a query string.
-// It does simple case-insensitive substring matching.
+// It does simple case-insensitive substring matching, after expanding known
+// abbreviations such as "cst" or "oden" (see SetAliases).
 // For a production app, use a proper fuzzy library (e.g., https://github.com/sahilm/fuzzy).
 func FuzzyMatch(query string, sites []Site, count int) []Site {
-	query = strings.ToLower(query)
+	query = strings.ToLower(expandAlias(query))
 	var matches []Site
 
 	for _, site := range sites {
@@ -174,57 +203,64 @@ func FuzzyMatch(query string, sites []Site, count int) []Site {
 	return matches
 }
 
-// loadFixture loads test data from a JSON file instead of calling the real API.
-// This is used when SL_DRY_RUN=1, allowing you to develop offline.
-func (c *Client) loadFixture(siteID string) ([]Departure, error) {
-	// Construct the fixture path: fixtures/{siteID}.json
-	fixtureFile := fmt.Sprintf("fixtures/%s.json", siteID)
-
-	data, err := os.ReadFile(fixtureFile)
-	if err != nil {
-		return nil, fmt.Errorf("read fixture %s: %w", fixtureFile, err)
-	}
-
-	var respData DeparturesResponse
-	if err := json.Unmarshal(data, &respData); err != nil {
-		return nil, fmt.Errorf("unmarshal fixture: %w", err)
-	}
-
-	return respData.Departures, nil
-}
-
 // FormatDeparture formats a single departure for display.
+// th decides what counts as early or late; most callers pass format.DefaultThresholds.
 // This is a pure function (no I/O, no side effects).
 // Pure functions are easy to test.
-func FormatDeparture(dep Departure) string {
-	// Determine if the bus is early, late, or on time.
-	delta := dep.Expected.Sub(dep.Scheduled)
+func FormatDeparture(dep Departure, th format.Thresholds) string {
+	l := format.English
 
-	status := ""
-	if delta < -30*time.Second { // more than 30s early
-		status = fmt.Sprintf("EARLY −%dm", int((-delta).Minutes()))
-	} else if delta > 30*time.Second { // more than 30s late
-		status = fmt.Sprintf("+%dm", int(delta.Minutes()))
-	} else {
-		status = "on time"
+	// Determine if the bus is early, late, or on time.
+	status := format.Delay(l, th, dep.Scheduled, dep.Expected)
+	if status == "" {
+		status = l.OnTime
 	}
 
-	// Format: "HH:mm Direction (status)"
-	timeStr := dep.Expected.Format("15:04")
-	return fmt.Sprintf("%s %s (%s)", timeStr, dep.Direction, status)
+	// Format: "HH:mm Direction (status)", plus ", platform X" when SL gives one.
+	return fmt.Sprintf("%s %s (%s)%s", format.Clock(dep.Expected), dep.Direction, status,
+		format.Platform(l, dep.StopPoint.Designation))
 }
 
 // FormatDepartures formats a list of departures.
 // We'll show only the next 3 departures to keep the Telegram message concise.
-func FormatDepartures(departures []Departure, count int) string {
+func FormatDepartures(departures []Departure, count int, th format.Thresholds) string {
 	if count > len(departures) {
 		count = len(departures)
 	}
 
 	result := ""
 	for i := 0; i < count; i++ {
-		result += FormatDeparture(departures[i]) + "\n"
+		result += FormatDeparture(departures[i], th) + "\n"
 	}
 
 	return result
 }
+
+// FormatDeparturesRelative is an alternative layout that leads with the line and
+// the minutes until departure, e.g. "26 Gullmarsplan · 4 min (+1m)".
+// It is being trialled against FormatDepartures; see the bot's canary setting.
+func FormatDeparturesRelative(departures []Departure, count int, now time.Time, th format.Thresholds) string {
+	if count > len(departures) {
+		count = len(departures)
+	}
+
+	result := ""
+	for _, dep := range departures[:count] {
+		result += FormatDepartureRelative(dep, now, th) + "\n"
+	}
+
+	return result
+}
+
+// FormatDepartureRelative formats one departure in the FormatDeparturesRelative layout.
+func FormatDepartureRelative(dep Departure, now time.Time, th format.Thresholds) string {
+	l := format.English
+
+	status := format.Delay(l, th, dep.Scheduled, dep.Expected)
+	if status != "" {
+		status = " (" + status + ")"
+	}
+
+	return fmt.Sprintf("%s %s · %s%s%s", dep.Line, dep.Direction, format.Countdown(l, dep.Expected, now), status,
+		format.Platform(l, dep.StopPoint.Designation))
+}
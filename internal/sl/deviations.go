@@ -0,0 +1,44 @@
+package sl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Deviation is a disruption notice attached to a departure, e.g. a closed
+// stop or a diversion. SL sends objects; older responses and our fixtures may
+// use plain strings, which decode into Message.
+type Deviation struct {
+	Message         string `json:"message"`
+	Consequence     string `json:"consequence,omitempty"`      // e.g. "INFORMATION", "CANCELLED"
+	ImportanceLevel int    `json:"importance_level,omitempty"` // higher is more important
+}
+
+// UnmarshalJSON accepts either a deviation object or a bare message string.
+func (d *Deviation) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*d = Deviation{}
+		return json.Unmarshal(data, &d.Message)
+	}
+
+	// deviationJSON has Deviation's fields but not this method, so decoding doesn't recurse.
+	type deviationJSON Deviation
+	var raw deviationJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("deviation: %w", err)
+	}
+	*d = Deviation(raw)
+	return nil
+}
+
+// Truncate shortens the message to at most n runes, ending in "…" when cut.
+// It reports whether anything was cut.
+func (d Deviation) Truncate(n int) (string, bool) {
+	msg := strings.Join(strings.Fields(d.Message), " ")
+	runes := []rune(msg)
+	if len(runes) <= n {
+		return msg, false
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…", true
+}
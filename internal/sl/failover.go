@@ -0,0 +1,187 @@
+package sl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahmad/slbot/internal/logging"
+)
+
+// unhealthyFor is how long a base URL is tried last after it fails.
+const unhealthyFor = 30 * time.Second
+
+// baseURLs is the client's list of API roots with passive health tracking:
+// a root that fails with a network error or 5xx moves to the back of the
+// queue until unhealthyFor has passed. Nothing is ever skipped outright, so
+// if every root is marked down they are still all tried.
+type baseURLs struct {
+	mu        sync.Mutex
+	urls      []string
+	downUntil map[string]time.Time
+	// outageSince is when a request last found every root failing, zero while
+	// any root answers; see Client.Outage.
+	outageSince time.Time
+	lastSuccess time.Time // when a root last answered, see Client.LastSuccess
+}
+
+func newBaseURLs(urls ...string) *baseURLs {
+	b := &baseURLs{downUntil: make(map[string]time.Time)}
+	for _, u := range urls {
+		b.urls = append(b.urls, strings.TrimRight(u, "/"))
+	}
+	return b
+}
+
+// order returns the roots to try: healthy ones in configured order, then the rest.
+func (b *baseURLs) order(now time.Time) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var healthy, down []string
+	for _, u := range b.urls {
+		if now.Before(b.downUntil[u]) {
+			down = append(down, u)
+		} else {
+			healthy = append(healthy, u)
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (b *baseURLs) markDown(u string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.downUntil[u] = now.Add(unhealthyFor)
+}
+
+func (b *baseURLs) markUp(u string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.downUntil, u)
+	b.outageSince = time.Time{}
+	b.lastSuccess = now
+}
+
+// markOutage records that no root answered; the first failure sets the start time.
+func (b *baseURLs) markOutage(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.outageSince.IsZero() {
+		b.outageSince = now
+	}
+}
+
+// Outage reports whether SL is currently unreachable: the last request failed
+// with network errors or 5xx on every base URL. since is when that started.
+// It clears on the next successful request.
+func (c *Client) Outage() (since time.Time, down bool) {
+	c.bases.mu.Lock()
+	defer c.bases.mu.Unlock()
+
+	return c.bases.outageSince, !c.bases.outageSince.IsZero()
+}
+
+// LastSuccess is when SL last answered a request, zero if it never has.
+// Cached responses don't count; in dry-run mode the fixtures are what answers.
+func (c *Client) LastSuccess() time.Time {
+	c.bases.mu.Lock()
+	defer c.bases.mu.Unlock()
+
+	return c.bases.lastSuccess
+}
+
+// SetBaseURLs configures one or more API roots, e.g. the public SL endpoint and
+// an slproxy instance. Requests go to the first healthy root and fail over to
+// the next on network errors or 5xx responses. Call it before the client is used.
+func (c *Client) SetBaseURLs(urls ...string) {
+	if len(urls) == 0 {
+		return
+	}
+	c.bases = newBaseURLs(urls...)
+}
+
+// BaseURLHealth reports each configured root and whether it is currently marked down.
+func (c *Client) BaseURLHealth() map[string]bool {
+	c.bases.mu.Lock()
+	defer c.bases.mu.Unlock()
+
+	now := time.Now()
+	health := make(map[string]bool, len(c.bases.urls))
+	for _, u := range c.bases.urls {
+		health[u] = !now.Before(c.bases.downUntil[u])
+	}
+	return health
+}
+
+// errRetryable marks failures worth trying on another base URL.
+var errRetryable = errors.New("retryable")
+
+// get fetches path (e.g. "/sites") from the first base URL that answers, recording
+// each attempt against endpoint's budget, and returns the 200 response body.
+func (c *Client) get(ctx context.Context, endpoint, path string) ([]byte, error) {
+	var lastErr error
+	for _, base := range c.bases.order(time.Now()) {
+		body, err := c.getFrom(ctx, endpoint, base+path)
+		if err == nil {
+			c.bases.markUp(base, time.Now())
+			return body, nil
+		}
+		lastErr = err
+		if !errors.Is(err, errRetryable) || ctx.Err() != nil {
+			return nil, err
+		}
+		c.bases.markDown(base, time.Now())
+	}
+	// Every root failed in a way that points at SL rather than the request.
+	c.bases.markOutage(time.Now())
+	return nil, lastErr
+}
+
+// getFrom performs a single GET against url.
+func (c *Client) getFrom(ctx context.Context, endpoint, url string) ([]byte, error) {
+	// http.NewRequestWithContext attaches the context to the HTTP request.
+	// If the context is cancelled (e.g., timeout), the request will be interrupted.
+	req, err := http.NewRequestWithContext(c.withConnTrace(ctx), "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	start := time.Now()
+	c.budget.record(endpoint, start)
+	resp, err := c.httpClient.Do(req)
+	slog.Debug("sl request",
+		logging.KeyEndpoint, endpoint,
+		logging.KeyLatencyMs, time.Since(start).Milliseconds(),
+		"ok", err == nil && resp.StatusCode == http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w (%w)", err, errRetryable)
+	}
+	// Always close response body to avoid leaking connections.
+	// defer ensures this happens even if we return early on error.
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("status code: %d (%w)", resp.StatusCode, errRetryable)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	// io.ReadAll reads the entire response into memory.
+	// For small responses (like SL departures), this is fine.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w (%w)", err, errRetryable)
+	}
+	return body, nil
+}
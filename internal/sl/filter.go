@@ -0,0 +1,70 @@
+package sl
+
+import "time"
+
+// FilterDepartures keeps departures on line (any line if "") travelling in
+// directionCode (any direction if 0).
+func FilterDepartures(departures []Departure, line string, directionCode int) []Departure {
+	var kept []Departure
+	for _, dep := range departures {
+		if line != "" && dep.Line != line {
+			continue
+		}
+		if directionCode != 0 && dep.DirectionCode != directionCode {
+			continue
+		}
+		kept = append(kept, dep)
+	}
+	return kept
+}
+
+// DedupJourneys drops repeats of the same journey, which SL can list once per
+// stop point when a stop area has several. The first occurrence wins.
+// Departures without a journey ID are always kept.
+func DedupJourneys(departures []Departure) []Departure {
+	seen := make(map[int64]bool, len(departures))
+	kept := departures[:0:0]
+	for _, dep := range departures {
+		if dep.Journey.ID != 0 {
+			if seen[dep.Journey.ID] {
+				continue
+			}
+			seen[dep.Journey.ID] = true
+		}
+		kept = append(kept, dep)
+	}
+	return kept
+}
+
+// TowardsStop keeps the departures at an origin stop that go on to call at a
+// destination stop, given departures at both. A departure goes there if its
+// journey is seen later at the destination; since that only covers the
+// destination's departure window, other departures of a line and direction
+// shown to go there are kept as well. It returns nil if none match.
+func TowardsStop(origin, destination []Departure) []Departure {
+	arrives := make(map[int64]time.Time, len(destination))
+	for _, dep := range destination {
+		if dep.Journey.ID != 0 {
+			arrives[dep.Journey.ID] = dep.Expected
+		}
+	}
+
+	type route struct {
+		line          string
+		directionCode int
+	}
+	routes := make(map[route]bool)
+	for _, dep := range origin {
+		if at, ok := arrives[dep.Journey.ID]; ok && dep.Journey.ID != 0 && at.After(dep.Expected) {
+			routes[route{dep.Line, dep.DirectionCode}] = true
+		}
+	}
+
+	var kept []Departure
+	for _, dep := range origin {
+		if routes[route{dep.Line, dep.DirectionCode}] {
+			kept = append(kept, dep)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,45 @@
+package sl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTowardsStop(t *testing.T) {
+	at := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	dep := func(line string, direction int, journey int64, minutes int) Departure {
+		return Departure{Line: line, DirectionCode: direction, Journey: Journey{ID: journey},
+			Expected: at.Add(time.Duration(minutes) * time.Minute)}
+	}
+	origin := []Departure{
+		dep("26", 1, 101, 2),
+		dep("26", 2, 201, 4),
+		dep("4", 1, 301, 5),
+		dep("26", 1, 102, 50), // past the destination's window, same route as 101
+	}
+
+	tests := []struct {
+		name        string
+		destination []Departure
+		want        []int64
+	}{
+		{"journey seen later", []Departure{dep("26", 1, 101, 12)}, []int64{101, 102}},
+		{"journey seen earlier", []Departure{dep("26", 2, 201, 1)}, nil},
+		{"several routes", []Departure{dep("26", 1, 101, 12), dep("4", 1, 301, 9)}, []int64{101, 301, 102}},
+		{"no shared journeys", []Departure{dep("55", 1, 901, 3)}, nil},
+		{"no destination departures", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TowardsStop(origin, tt.destination)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d departures, want %d", len(got), len(tt.want))
+			}
+			for i, d := range got {
+				if d.Journey.ID != tt.want[i] {
+					t.Errorf("departure %d: journey %d, want %d", i, d.Journey.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package sl
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// embeddedFixtures are the dry-run responses built into the binary, so dry-run
+// works from any working directory (and in containers).
+//
+//go:embed fixtures/*.json
+var embeddedFixtures embed.FS
+
+// defaultFixtures returns SL_FIXTURES_DIR if set, otherwise the embedded fixtures.
+// Fixture files are named {siteID}.json for departures and sites.json for the sites list.
+func defaultFixtures() fs.FS {
+	if dir := os.Getenv("SL_FIXTURES_DIR"); dir != "" {
+		return os.DirFS(dir)
+	}
+	sub, err := fs.Sub(embeddedFixtures, "fixtures")
+	if err != nil {
+		panic(err) // the directory is embedded above, so this can't happen
+	}
+	return sub
+}
+
+// SetFixtures replaces the files dry-run mode reads from, e.g. with os.DirFS.
+// Call it before the client is used, or between requests in tests.
+func (c *Client) SetFixtures(fsys fs.FS) {
+	c.fixtures = fsys
+}
+
+// readFixture returns the named fixture file.
+func (c *Client) readFixture(name string) ([]byte, error) {
+	return fs.ReadFile(c.fixtures, name)
+}
+
+// fixtureTransport is the dry-run http.RoundTripper: it answers SL API requests
+// from c's fixtures, whatever the host, and injects c's Faults. Requests for
+// which there is no fixture get a 404.
+type fixtureTransport struct {
+	c *Client
+}
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.c.faults.injectDelay(req.Context()); err != nil {
+		return nil, err
+	}
+	if roll(t.c.faults.ErrorRate) {
+		return fixtureResponse(req, http.StatusInternalServerError, []byte("injected failure")), nil
+	}
+
+	// "/v1/sites" is the sites list, "/v1/sites/{siteID}/departures" a site's departures.
+	var name string
+	siteDir, isDepartures := strings.CutSuffix(req.URL.Path, "/departures")
+	switch {
+	case isDepartures:
+		name = path.Base(siteDir) + ".json"
+	case path.Base(req.URL.Path) == "sites":
+		name = "sites.json"
+	default:
+		return fixtureResponse(req, http.StatusNotFound, nil), nil
+	}
+
+	data, err := t.c.readFixture(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fixtureResponse(req, http.StatusNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read fixture %s: %w", name, err)
+	}
+	if isDepartures {
+		data = t.c.faults.corrupt(data)
+	}
+	return fixtureResponse(req, http.StatusOK, data), nil
+}
+
+func fixtureResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,54 @@
+package sl
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Forecast bounds accepted by SetForecast and GetDeparturesForecast. The API's
+// own default window applies when no forecast is given.
+const (
+	MinForecast = 5 * time.Minute
+	MaxForecast = 4 * time.Hour
+)
+
+// SetForecast sets the departures window requested by GetDepartures: SL returns
+// departures up to this far ahead. 0 restores the API's default. Call it before
+// the client is used.
+func (c *Client) SetForecast(window time.Duration) error {
+	if window != 0 {
+		if err := checkForecast(window); err != nil {
+			return err
+		}
+	}
+	c.forecast = window
+	return nil
+}
+
+// GetDeparturesForecast is GetDepartures with its own departures window
+// (the API's "forecast" parameter), overriding SetForecast for one call.
+// Dry-run fixtures ignore the window.
+func (c *Client) GetDeparturesForecast(ctx context.Context, siteID string, window time.Duration) ([]Departure, error) {
+	if err := checkForecast(window); err != nil {
+		return nil, err
+	}
+	return c.getDepartures(ctx, siteID, window)
+}
+
+func checkForecast(window time.Duration) error {
+	if window < MinForecast || window > MaxForecast {
+		return fmt.Errorf("forecast %v outside %v–%v", window, MinForecast, MaxForecast)
+	}
+	return nil
+}
+
+// departuresPath is the departures endpoint for siteID, with the forecast
+// parameter in whole minutes when window is set.
+func departuresPath(siteID string, window time.Duration) string {
+	path := fmt.Sprintf("/sites/%s/departures", siteID)
+	if window > 0 {
+		path += fmt.Sprintf("?forecast=%d", int(window/time.Minute))
+	}
+	return path
+}
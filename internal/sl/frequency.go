@@ -0,0 +1,66 @@
+package sl
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// Headway describes how often a line runs in one direction, derived from the gaps
+// between its consecutive departures in a departures response.
+type Headway struct {
+	Line      string
+	Direction string
+	Min       time.Duration
+	Max       time.Duration
+}
+
+// LineHeadways computes a Headway for every line/direction with at least two departures.
+// The result is ordered by first appearance in departures.
+func LineHeadways(departures []Departure) []Headway {
+	type key struct{ line, direction string }
+
+	times := make(map[key][]time.Time)
+	var order []key
+	for _, dep := range departures {
+		k := key{dep.Line, dep.Direction}
+		if _, seen := times[k]; !seen {
+			order = append(order, k)
+		}
+		times[k] = append(times[k], dep.Expected)
+	}
+
+	var result []Headway
+	for _, k := range order {
+		ts := times[k]
+		if len(ts) < 2 {
+			continue
+		}
+		sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+
+		h := Headway{Line: k.line, Direction: k.direction}
+		for i := 1; i < len(ts); i++ {
+			gap := ts[i].Sub(ts[i-1])
+			if gap <= 0 {
+				continue // duplicate stop points can report the same departure twice
+			}
+			if h.Min == 0 || gap < h.Min {
+				h.Min = gap
+			}
+			if gap > h.Max {
+				h.Max = gap
+			}
+		}
+		if h.Max > 0 {
+			result = append(result, h)
+		}
+	}
+
+	return result
+}
+
+// FormatHeadway renders a headway as "every 15 min" or "every 7–8 min".
+func FormatHeadway(h Headway) string {
+	return format.MinuteRange(format.English, h.Min, h.Max)
+}
@@ -0,0 +1,62 @@
+package sl
+
+import (
+	"strings"
+)
+
+// SiteGroup is a parent stop together with its named variants,
+// e.g. "Solna centrum" with "Solna centrum norra".
+type SiteGroup struct {
+	Parent   Site
+	Children []Site
+}
+
+// GroupSites collapses variant stops under their parent.
+// A site is a variant of another when its name is the other's name followed by a
+// space and a suffix ("Solna centrum" → "Solna centrum norra"). Only parents present
+// in sites are used; the longest matching parent wins. Groups keep the input order.
+func GroupSites(sites []Site) []SiteGroup {
+	names := make([]string, len(sites))
+	for i, s := range sites {
+		names[i] = strings.ToLower(s.Name)
+	}
+
+	// parentOf[i] is the index of site i's parent, or -1 for top-level sites.
+	parentOf := make([]int, len(sites))
+	for i := range sites {
+		parentOf[i] = -1
+		for j := range sites {
+			if i == j || !strings.HasPrefix(names[i], names[j]+" ") {
+				continue
+			}
+			if parentOf[i] == -1 || len(names[j]) > len(names[parentOf[i]]) {
+				parentOf[i] = j
+			}
+		}
+	}
+
+	// Resolve chains ("a b c" → "a b" → "a") to the top-level ancestor.
+	root := func(i int) int {
+		for parentOf[i] != -1 {
+			i = parentOf[i]
+		}
+		return i
+	}
+
+	groupAt := make(map[int]int) // root site index -> position in groups
+	var groups []SiteGroup
+	for i, s := range sites {
+		r := root(i)
+		pos, ok := groupAt[r]
+		if !ok {
+			pos = len(groups)
+			groupAt[r] = pos
+			groups = append(groups, SiteGroup{Parent: sites[r]})
+		}
+		if r != i {
+			groups[pos].Children = append(groups[pos].Children, s)
+		}
+	}
+
+	return groups
+}
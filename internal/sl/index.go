@@ -0,0 +1,105 @@
+package sl
+
+import (
+	"strings"
+)
+
+// SiteIndex is an in-memory trigram index over a sites list.
+// Scanning all ~10k SL sites with strings.Contains for every keystroke is too slow
+// for autocomplete, so we map every 3-rune window of each lowercased name to the
+// positions of the sites containing it. A query only has to verify the sites in
+// its rarest trigram's posting list.
+type SiteIndex struct {
+	sites    []Site
+	names    []string         // lowercased names, parallel to sites
+	trigrams map[string][]int // trigram -> ascending positions in sites
+}
+
+// NewSiteIndex builds an index over sites.
+// The index keeps a reference to the slice; callers should treat it as read-only afterwards.
+func NewSiteIndex(sites []Site) *SiteIndex {
+	idx := &SiteIndex{
+		sites:    sites,
+		names:    make([]string, len(sites)),
+		trigrams: make(map[string][]int),
+	}
+
+	for i, site := range sites {
+		name := strings.ToLower(site.Name)
+		idx.names[i] = name
+
+		for _, tri := range trigrams(name) {
+			postings := idx.trigrams[tri]
+			// A name can repeat a trigram ("sollentuna centrum"); store each position once.
+			if n := len(postings); n > 0 && postings[n-1] == i {
+				continue
+			}
+			idx.trigrams[tri] = append(postings, i)
+		}
+	}
+
+	return idx
+}
+
+// Len returns the number of indexed sites.
+func (idx *SiteIndex) Len() int {
+	return len(idx.sites)
+}
+
+// Sites returns the indexed sites list.
+func (idx *SiteIndex) Sites() []Site {
+	return idx.sites
+}
+
+// Match returns up to count sites whose name contains query (case-insensitive).
+// Known abbreviations ("cst", "oden", see SetAliases) are expanded first.
+// Results come back in the same order as the indexed list, so it is a drop-in
+// replacement for FuzzyMatch.
+func (idx *SiteIndex) Match(query string, count int) []Site {
+	query = strings.ToLower(expandAlias(query))
+	queryTrigrams := trigrams(query)
+
+	// Queries shorter than a trigram can't use the index; the list is small enough to scan.
+	if len(queryTrigrams) == 0 {
+		return FuzzyMatch(query, idx.sites, count)
+	}
+
+	// Verify only the candidates from the smallest posting list.
+	var candidates []int
+	for i, tri := range queryTrigrams {
+		postings, ok := idx.trigrams[tri]
+		if !ok {
+			return nil // some trigram appears in no name, so nothing can match
+		}
+		if i == 0 || len(postings) < len(candidates) {
+			candidates = postings
+		}
+	}
+
+	var matches []Site
+	for _, pos := range candidates {
+		if strings.Contains(idx.names[pos], query) {
+			matches = append(matches, idx.sites[pos])
+			if len(matches) >= count {
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// trigrams returns every 3-rune window of s.
+// We work on runes rather than bytes so names like "Frösunda" split on characters.
+func trigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 3 {
+		return nil
+	}
+
+	result := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		result = append(result, string(runes[i:i+3]))
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package sl
+
+import (
+	"math"
+	"sort"
+)
+
+// NearbySite is a site with its distance from a point.
+type NearbySite struct {
+	Site
+	Meters float64
+}
+
+// Nearest returns up to count sites within maxMeters of (lat, lon), closest
+// first. Sites without a position are skipped. A linear scan is fine for the
+// few thousand SL sites.
+func Nearest(sites []Site, lat, lon float64, count int, maxMeters float64) []NearbySite {
+	var nearby []NearbySite
+	for _, site := range sites {
+		if site.Lat == 0 && site.Lon == 0 {
+			continue
+		}
+		if d := DistanceMeters(lat, lon, site.Lat, site.Lon); d <= maxMeters {
+			nearby = append(nearby, NearbySite{Site: site, Meters: d})
+		}
+	}
+
+	sort.Slice(nearby, func(i, j int) bool { return nearby[i].Meters < nearby[j].Meters })
+	if len(nearby) > count {
+		nearby = nearby[:count]
+	}
+	return nearby
+}
+
+// DistanceMeters is the great-circle (haversine) distance between two WGS84 points.
+func DistanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,29 @@
+package sl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// secretParams matches credential-looking query parameters in URLs embedded in
+// responses, e.g. "?key=abc" or "&access_token=abc".
+var secretParams = regexp.MustCompile(`(?i)([?&](?:key|api_?key|token|access_token|secret)=)[^&"\s]+`)
+
+// RawDepartures returns the upstream departures JSON for siteID, indented and
+// with credential query parameters redacted. It bypasses the cache and is meant
+// for debugging how responses map onto Departure.
+func (c *Client) RawDepartures(ctx context.Context, siteID string) ([]byte, error) {
+	body, err := c.get(ctx, EndpointDepartures, fmt.Sprintf("/sites/%s/departures", siteID))
+	if err != nil {
+		return nil, err
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "  "); err != nil {
+		return nil, fmt.Errorf("indent json: %w", err)
+	}
+	return secretParams.ReplaceAll(pretty.Bytes(), []byte("${1}REDACTED")), nil
+}
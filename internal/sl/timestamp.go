@@ -0,0 +1,72 @@
+package sl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mahmad/slbot/internal/format"
+)
+
+// timestampLayouts are tried in order. The first carries an offset; the rest are
+// local wall-clock times as SL sometimes sends them ("2025-12-27T08:15:00").
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ParseTimestamp parses an SL timestamp. Values without a UTC offset are taken
+// as Europe/Stockholm local time: a time in the hour repeated when DST ends
+// resolves to its second (standard time) occurrence, and one in the hour skipped
+// when DST starts to the instant an hour later on the wall clock, as time.Date
+// does. An empty string gives the zero time.
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(timestampLayouts[0], s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timestampLayouts[1:] {
+		if t, err := time.ParseInLocation(layout, s, format.Stockholm); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", s)
+}
+
+// UnmarshalJSON decodes a Departure, parsing its timestamps with ParseTimestamp
+// instead of encoding/json's strict RFC 3339.
+func (d *Departure) UnmarshalJSON(data []byte) error {
+	// departureJSON has Departure's fields but not its methods, so decoding into it
+	// doesn't recurse back here.
+	type departureJSON Departure
+	var raw struct {
+		departureJSON
+		Scheduled string `json:"scheduled"`
+		Expected  string `json:"expected"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	scheduled, err := ParseTimestamp(raw.Scheduled)
+	if err != nil {
+		return fmt.Errorf("scheduled: %w", err)
+	}
+	expected, err := ParseTimestamp(raw.Expected)
+	if err != nil {
+		return fmt.Errorf("expected: %w", err)
+	}
+
+	*d = Departure(raw.departureJSON)
+	d.Scheduled = scheduled
+	d.Expected = expected
+	return nil
+}
@@ -0,0 +1,121 @@
+package sl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // RFC 3339 in UTC, "" for the zero time
+	}{
+		// Offset-less local times, as SL's departures endpoint sends them.
+		{"local winter", "2025-12-27T08:15:00", "2025-12-27T07:15:00Z"},
+		{"local summer", "2025-06-16T08:15:00", "2025-06-16T06:15:00Z"},
+		{"local fraction", "2025-12-27T08:15:00.000", "2025-12-27T07:15:00Z"},
+		{"local no seconds", "2025-12-27T08:15", "2025-12-27T07:15:00Z"},
+		{"local space", "2025-12-27 08:15:00", "2025-12-27T07:15:00Z"},
+		{"local space no seconds", "2025-12-27 08:15", "2025-12-27T07:15:00Z"},
+		{"surrounding space", " 2025-12-27T08:15:00\n", "2025-12-27T07:15:00Z"},
+
+		// RFC 3339 with an offset is taken as is.
+		{"utc", "2025-12-27T17:45:15Z", "2025-12-27T17:45:15Z"},
+		{"cet offset", "2025-12-27T08:15:00+01:00", "2025-12-27T07:15:00Z"},
+		{"cest offset", "2025-06-16T08:15:00+02:00", "2025-06-16T06:15:00Z"},
+		{"offset fraction", "2025-06-16T08:15:00.123+02:00", "2025-06-16T06:15:00.123Z"},
+
+		// DST starts 2025-03-30: 02:00 CET jumps to 03:00 CEST.
+		{"before spring gap", "2025-03-30T01:59:00", "2025-03-30T00:59:00Z"},
+		{"in spring gap", "2025-03-30T02:30:00", "2025-03-30T01:30:00Z"},
+		{"after spring gap", "2025-03-30T03:00:00", "2025-03-30T01:00:00Z"},
+		{"spring gap with offset", "2025-03-30T03:30:00+02:00", "2025-03-30T01:30:00Z"},
+
+		// DST ends 2025-10-26: 03:00 CEST falls back to 02:00 CET.
+		{"before autumn overlap", "2025-10-26T01:59:00", "2025-10-25T23:59:00Z"},
+		{"in autumn overlap", "2025-10-26T02:30:00", "2025-10-26T01:30:00Z"},
+		{"autumn overlap first with offset", "2025-10-26T02:30:00+02:00", "2025-10-26T00:30:00Z"},
+		{"after autumn overlap", "2025-10-26T03:00:00", "2025-10-26T02:00:00Z"},
+
+		{"empty", "", ""},
+		{"blank", "  ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.in)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q): %v", tt.in, err)
+			}
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("ParseTimestamp(%q) = %v, want the zero time", tt.in, got)
+				}
+				return
+			}
+			if s := got.UTC().Format(time.RFC3339Nano); s != tt.want {
+				t.Errorf("ParseTimestamp(%q) = %s, want %s", tt.in, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampInvalid(t *testing.T) {
+	for _, in := range []string{"garbage", "2025-12-27", "08:15", "27/12/2025 08:15", "2025-13-01T08:15:00", "2025-12-27T25:00:00"} {
+		if got, err := ParseTimestamp(in); err == nil {
+			t.Errorf("ParseTimestamp(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestDepartureUnmarshalJSON(t *testing.T) {
+	// Shaped like a real departures response, with SL's offset-less local
+	// times; the second entry has no expected time, as for departures SL has
+	// no prediction for yet.
+	data := `{
+		"departures": [
+			{
+				"scheduled": "2025-10-26T02:30:00",
+				"expected": "2025-10-26T02:33:00",
+				"line": "26",
+				"direction": "Gullmarsplan",
+				"displayText": "3 min",
+				"stopArea": {"name": "Frösunda torg", "siteId": 3455},
+				"deviations": [],
+				"directionCode": 2,
+				"stopPoint": {"id": 34551, "name": "Frösunda torg", "designation": "B"},
+				"journey": {"id": 2025102600260, "state": "NORMALPROGRESS"}
+			},
+			{"scheduled": "2025-12-27T17:45:00Z", "line": "1"}
+		]
+	}`
+	var resp DeparturesResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(resp.Departures) != 2 {
+		t.Fatalf("got %d departures, want 2", len(resp.Departures))
+	}
+
+	dep := resp.Departures[0]
+	if want := time.Date(2025, 10, 26, 1, 30, 0, 0, time.UTC); !dep.Scheduled.Equal(want) {
+		t.Errorf("Scheduled = %v, want %v", dep.Scheduled, want)
+	}
+	if want := time.Date(2025, 10, 26, 1, 33, 0, 0, time.UTC); !dep.Expected.Equal(want) {
+		t.Errorf("Expected = %v, want %v", dep.Expected, want)
+	}
+	if dep.Line != "26" || dep.Direction != "Gullmarsplan" || dep.StopArea.SiteID != 3455 ||
+		dep.StopPoint.Designation != "B" || dep.Journey.ID != 2025102600260 {
+		t.Errorf("other fields not decoded: %+v", dep)
+	}
+
+	if !resp.Departures[1].Expected.IsZero() {
+		t.Errorf("missing expected = %v, want the zero time", resp.Departures[1].Expected)
+	}
+
+	var bad Departure
+	if err := json.Unmarshal([]byte(`{"scheduled": "soon"}`), &bad); err == nil {
+		t.Error("Unmarshal of a bad timestamp succeeded, want an error")
+	}
+}
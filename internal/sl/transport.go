@@ -0,0 +1,284 @@
+package sl
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mahmad/slbot/internal/httpx"
+)
+
+// TransportConfig tunes the HTTP transport used for SL API calls.
+// The defaults suit a single bot; the departure-watch features multiply request
+// volume, so pool sizes and timeouts can be raised via environment variables.
+type TransportConfig struct {
+	EnableHTTP2         bool
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 means unlimited
+	RequestTimeout      time.Duration
+
+	// Network is "tcp" (either address family), "tcp4" or "tcp6"; pin one
+	// family when the other is flaky on the host's network.
+	Network string
+	// FallbackDelay is how long a dual-stack dial waits on the first family
+	// before racing the other (RFC 6555 "happy eyeballs").
+	FallbackDelay time.Duration
+	// DNSRetries is how many extra times a dial is retried after a DNS lookup failure.
+	DNSRetries int
+
+	// Retries is how many extra times a failed GET is retried against the same
+	// host before failover moves on to the next base URL; 0 leaves it to failover.
+	Retries      int
+	RetryBackoff time.Duration
+	// RateLimit caps requests per second across all hosts (0 disables), with
+	// bursts of up to RateBurst.
+	RateLimit float64
+	RateBurst int
+	// BreakerThreshold consecutive failures against a host make further calls
+	// fail fast for BreakerCooldown (0 disables the breaker).
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// UserAgent is sent with every request when set.
+	UserAgent string
+	// Metrics, when set, counts every round trip; see httpx.Metrics.
+	Metrics *httpx.Metrics
+	// Base, when set, is the transport the middlewares wrap instead of one
+	// dialing the network with the settings above; see NewDryRunClient.
+	Base http.RoundTripper
+}
+
+// DefaultTransportConfig returns conservative settings for talking to SL.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		EnableHTTP2:         true,
+		DialTimeout:         5 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     0,
+		RequestTimeout:      15 * time.Second,
+		Network:             "tcp",
+		FallbackDelay:       300 * time.Millisecond,
+		DNSRetries:          2,
+		RetryBackoff:        200 * time.Millisecond,
+		RateBurst:           5,
+		BreakerCooldown:     30 * time.Second,
+		UserAgent:           "slbot",
+	}
+}
+
+// TransportConfigFromEnv starts from the defaults and applies any SL_HTTP_* overrides.
+// Invalid values are ignored so a typo can't take the bot down.
+//
+//	SL_HTTP2=0                       disable HTTP/2
+//	SL_HTTP_DIAL_TIMEOUT=3s          dial timeout
+//	SL_HTTP_TLS_TIMEOUT=3s           TLS handshake timeout
+//	SL_HTTP_IDLE_TIMEOUT=90s         idle connection lifetime
+//	SL_HTTP_MAX_IDLE=100             idle connections across all hosts
+//	SL_HTTP_MAX_IDLE_PER_HOST=10     idle connections per host
+//	SL_HTTP_MAX_CONNS_PER_HOST=20    total connections per host
+//	SL_HTTP_TIMEOUT=15s              whole-request timeout
+//	SL_HTTP_NETWORK=tcp4             address family: tcp, tcp4 or tcp6
+//	SL_HTTP_FALLBACK_DELAY=300ms     happy-eyeballs delay before trying the other family
+//	SL_HTTP_DNS_RETRIES=2            retries after a DNS lookup failure
+//	SL_HTTP_RETRIES=1                retries of a failed GET on the same host
+//	SL_HTTP_RETRY_BACKOFF=200ms      wait before the first retry, doubling after
+//	SL_HTTP_RATE=10                  requests per second, 0 for no limit
+//	SL_HTTP_RATE_BURST=5             requests allowed at once above the rate
+//	SL_HTTP_BREAKER_THRESHOLD=5      consecutive failures that open the breaker
+//	SL_HTTP_BREAKER_COOLDOWN=30s     how long an open breaker fails fast
+func TransportConfigFromEnv() TransportConfig {
+	cfg := DefaultTransportConfig()
+
+	if v := os.Getenv("SL_HTTP2"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.EnableHTTP2 = b
+		}
+	}
+	envDuration("SL_HTTP_DIAL_TIMEOUT", &cfg.DialTimeout)
+	envDuration("SL_HTTP_TLS_TIMEOUT", &cfg.TLSHandshakeTimeout)
+	envDuration("SL_HTTP_IDLE_TIMEOUT", &cfg.IdleConnTimeout)
+	envDuration("SL_HTTP_TIMEOUT", &cfg.RequestTimeout)
+	envInt("SL_HTTP_MAX_IDLE", &cfg.MaxIdleConns)
+	envInt("SL_HTTP_MAX_IDLE_PER_HOST", &cfg.MaxIdleConnsPerHost)
+	envInt("SL_HTTP_MAX_CONNS_PER_HOST", &cfg.MaxConnsPerHost)
+	envDuration("SL_HTTP_FALLBACK_DELAY", &cfg.FallbackDelay)
+	envInt("SL_HTTP_DNS_RETRIES", &cfg.DNSRetries)
+	envInt("SL_HTTP_RETRIES", &cfg.Retries)
+	envDuration("SL_HTTP_RETRY_BACKOFF", &cfg.RetryBackoff)
+	if v := os.Getenv("SL_HTTP_RATE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			cfg.RateLimit = f
+		}
+	}
+	envInt("SL_HTTP_RATE_BURST", &cfg.RateBurst)
+	envInt("SL_HTTP_BREAKER_THRESHOLD", &cfg.BreakerThreshold)
+	envDuration("SL_HTTP_BREAKER_COOLDOWN", &cfg.BreakerCooldown)
+	switch v := os.Getenv("SL_HTTP_NETWORK"); v {
+	case "tcp", "tcp4", "tcp6":
+		cfg.Network = v
+	}
+
+	return cfg
+}
+
+// NewHTTPClient builds an *http.Client from cfg, ready to pass to NewClient.
+// The transport is wrapped in the httpx middlewares cfg enables; see Middlewares.
+func NewHTTPClient(cfg TransportConfig) *http.Client {
+	if cfg.Base != nil {
+		return &http.Client{
+			Transport: httpx.Chain(cfg.Base, cfg.Middlewares()...),
+			Timeout:   cfg.RequestTimeout,
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:       cfg.DialTimeout,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: cfg.FallbackDelay,
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialWithDNSRetry(dialer, cfg.Network, cfg.DNSRetries),
+		ForceAttemptHTTP2:   cfg.EnableHTTP2,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+	}
+	if !cfg.EnableHTTP2 {
+		// A non-nil, empty TLSNextProto map is how net/http is told to stay on HTTP/1.1.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{
+		Transport: httpx.Chain(transport, cfg.Middlewares()...),
+		Timeout:   cfg.RequestTimeout,
+	}
+}
+
+// Middlewares lists the httpx middlewares cfg enables, outermost first:
+// logging and metrics see each request once, however many retries it takes,
+// and the breaker sits innermost so every attempt counts towards it.
+func (cfg TransportConfig) Middlewares() []httpx.Middleware {
+	mws := []httpx.Middleware{httpx.Logging()}
+	if cfg.Metrics != nil {
+		mws = append(mws, cfg.Metrics.Middleware())
+	}
+	if cfg.UserAgent != "" {
+		mws = append(mws, httpx.Header("User-Agent", cfg.UserAgent))
+	}
+	if cfg.Retries > 0 {
+		mws = append(mws, httpx.Retry(cfg.Retries, cfg.RetryBackoff))
+	}
+	if cfg.RateLimit > 0 {
+		mws = append(mws, httpx.RateLimit(cfg.RateLimit, max(cfg.RateBurst, 1)))
+	}
+	if cfg.BreakerThreshold > 0 {
+		mws = append(mws, httpx.CircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown))
+	}
+	return mws
+}
+
+// dialWithDNSRetry dials on network instead of the one net/http asks for, and
+// retries a few times with a short backoff when the DNS lookup itself fails,
+// which resolvers do intermittently. Other dial errors are returned at once.
+func dialWithDNSRetry(dialer *net.Dialer, network string, retries int) func(ctx context.Context, _, addr string) (net.Conn, error) {
+	if network == "" {
+		network = "tcp"
+	}
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		backoff := 100 * time.Millisecond
+		for attempt := 0; ; attempt++ {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			var dnsErr *net.DNSError
+			if err == nil || attempt >= retries || !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+				return conn, err
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, err
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// ConnStats counts how requests obtained their connections.
+// A low reuse ratio under load usually means the idle pool is too small.
+type ConnStats struct {
+	Requests int64         // connections obtained (one per request attempt)
+	Reused   int64         // served from the idle pool
+	New      int64         // freshly dialed
+	IdleTime time.Duration // total time reused connections spent idle
+}
+
+// connCounters is the live, concurrency-safe form of ConnStats.
+type connCounters struct {
+	requests   atomic.Int64
+	reused     atomic.Int64
+	fresh      atomic.Int64
+	idleTimeNs atomic.Int64
+}
+
+// ConnStats returns a snapshot of connection reuse since the client was created.
+func (c *Client) ConnStats() ConnStats {
+	return ConnStats{
+		Requests: c.conns.requests.Load(),
+		Reused:   c.conns.reused.Load(),
+		New:      c.conns.fresh.Load(),
+		IdleTime: time.Duration(c.conns.idleTimeNs.Load()),
+	}
+}
+
+// withConnTrace attaches an httptrace hook that records connection reuse for ctx's request.
+func (c *Client) withConnTrace(ctx context.Context) context.Context {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.conns.requests.Add(1)
+			if info.Reused {
+				c.conns.reused.Add(1)
+			} else {
+				c.conns.fresh.Add(1)
+			}
+			if info.WasIdle {
+				c.conns.idleTimeNs.Add(int64(info.IdleTime))
+			}
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
+
+// envDuration overwrites *dst with the parsed duration in env var name, if valid.
+func envDuration(name string, dst *time.Duration) {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			*dst = d
+		}
+	}
+}
+
+// envInt overwrites *dst with the parsed integer in env var name, if valid.
+func envInt(name string, dst *int) {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			*dst = n
+		}
+	}
+}
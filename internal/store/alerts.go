@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// deviationSentTTL is how long a pushed deviation is remembered. A disruption
+// still running after that is pushed again, as a reminder.
+const deviationSentTTL = 30 * 24 * time.Hour
+
+// DeviationAlert pushes new SL deviations (disruption notices) on one line,
+// or on every line when Line is "", as they appear on the departures from the
+// user's home and work stops.
+type DeviationAlert struct {
+	ID     int    `json:"id"`
+	ChatID int64  `json:"chatId"`
+	Line   string `json:"line,omitempty"` // "" for the whole commute
+
+	// Sent maps keys of deviations already pushed to when, so each is sent
+	// once; see MarkDeviationSent.
+	Sent map[string]time.Time `json:"sent,omitempty"`
+}
+
+// AddDeviationAlert stores an alert for userID and returns it with its ID assigned.
+// It fails with ErrUserLimit when the user has Limits.DeviationAlertsPerUser already.
+// Alerts only poll the user's own stops, so there is no global cap.
+func (s *UserStore) AddDeviationAlert(userID int64, alert DeviationAlert) (DeviationAlert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := s.userLocked(userID)
+	if max := s.limits.DeviationAlertsPerUser; max > 0 && len(prefs.DeviationAlerts) >= max {
+		return DeviationAlert{}, fmt.Errorf("%w: user %d has %d of %d deviation alerts", ErrUserLimit, userID, len(prefs.DeviationAlerts), max)
+	}
+
+	alert.ID = 1
+	for _, existing := range prefs.DeviationAlerts {
+		if existing.ID >= alert.ID {
+			alert.ID = existing.ID + 1
+		}
+	}
+	prefs.DeviationAlerts = append(prefs.DeviationAlerts, alert)
+
+	return alert, s.saveToFile()
+}
+
+// RemoveDeviationAlert deletes the alert with the given ID.
+func (s *UserStore) RemoveDeviationAlert(userID int64, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return fmt.Errorf("deviation alert %d not found", id)
+	}
+	for i, alert := range prefs.DeviationAlerts {
+		if alert.ID == id {
+			prefs.DeviationAlerts = append(prefs.DeviationAlerts[:i], prefs.DeviationAlerts[i+1:]...)
+			return s.saveToFile()
+		}
+	}
+	return fmt.Errorf("deviation alert %d not found", id)
+}
+
+// AllDeviationAlerts returns a snapshot of every user's deviation alerts, keyed by userID.
+func (s *UserStore) AllDeviationAlerts() map[int64][]DeviationAlert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[int64][]DeviationAlert)
+	for userID, prefs := range s.prefs {
+		for _, alert := range prefs.DeviationAlerts {
+			alert.Sent = maps.Clone(alert.Sent)
+			all[userID] = append(all[userID], alert)
+		}
+	}
+	return all
+}
+
+// MarkDeviationSent records that the deviation identified by key was pushed for
+// an alert at now, forgetting ones pushed more than deviationSentTTL ago.
+func (s *UserStore) MarkDeviationSent(userID int64, id int, key string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return fmt.Errorf("deviation alert %d not found", id)
+	}
+	for i := range prefs.DeviationAlerts {
+		alert := &prefs.DeviationAlerts[i]
+		if alert.ID != id {
+			continue
+		}
+		if alert.Sent == nil {
+			alert.Sent = make(map[string]time.Time)
+		}
+		for k, at := range alert.Sent {
+			if now.Sub(at) > deviationSentTTL {
+				delete(alert.Sent, k)
+			}
+		}
+		alert.Sent[key] = now
+		return s.saveToFile()
+	}
+	return fmt.Errorf("deviation alert %d not found", id)
+}
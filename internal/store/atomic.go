@@ -0,0 +1,191 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SetFileLocking lets several bot processes share one prefs file. Each save
+// takes an exclusive flock on "<file>.lock", re-reads the file and merges in
+// what other processes changed (see mergeDocs) before writing, so neither
+// overwrites the other's changes. It is off by default; without flock (non-unix
+// platforms) saves are still atomic but not merged safely.
+func (s *UserStore) SetFileLocking(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fileLocking = enabled
+}
+
+// writeFileAtomic replaces path with data without ever leaving a partly written
+// file behind: the data goes to a temp file in the same directory, is synced,
+// and is then renamed over path. A crash leaves either the old or the new file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Remove the temp file if anything below fails; after the rename it no longer exists.
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	// The rename is only durable once the directory entry is.
+	if err = syncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+	return nil
+}
+
+// mergeLocked re-reads s.file and, if another process has changed it since s
+// last read or wrote it, merges those changes into memory. The caller must hold
+// s.mu for writing and the file lock.
+func (s *UserStore) mergeLocked() error {
+	theirs, err := os.ReadFile(s.file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reload prefs file: %w", err)
+	}
+	if bytes.Equal(theirs, s.base) {
+		return nil // nobody else has written since
+	}
+
+	ours, err := s.encodeLocked()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errEncode, err)
+	}
+	merged, err := mergeDocs(s.base, ours, theirs)
+	if err != nil {
+		return fmt.Errorf("merge prefs file: %w", err)
+	}
+	fresh, err := decodeState(merged)
+	if err != nil {
+		return fmt.Errorf("merge prefs file: %w", err)
+	}
+	s.adoptLocked(fresh)
+	return nil
+}
+
+// mergeDocs is a three-way merge of persistence documents: base is the file as
+// this process last saw it, ours is its current state and theirs is the file
+// now. JSON objects (the users map, each user's preferences, ...) are merged key
+// by key, so a field only this process changed keeps its value, while fields,
+// records and deletions from other processes are kept too. Anything else (a
+// subscription list, say) is replaced whole; when both sides changed the same
+// value, ours wins.
+func mergeDocs(base, ours, theirs []byte) ([]byte, error) {
+	var docs [3]json.RawMessage
+	for i, data := range [][]byte{base, ours, theirs} {
+		doc, err := normalizeDoc(data)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+	return mergeJSON(docs[0], docs[1], docs[2], 0)
+}
+
+// normalizeDoc compacts a persistence document so equal values compare equal
+// byte for byte. Empty input is an empty document and a legacy flat file
+// (userID -> prefs) becomes its "users" section.
+func normalizeDoc(data []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.RawMessage(`{"users":{}}`), nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &top); err != nil {
+		return nil, err
+	}
+	if _, ok := top["users"]; !ok {
+		return json.Marshal(map[string]json.RawMessage{"users": buf.Bytes()})
+	}
+	return buf.Bytes(), nil
+}
+
+// recordDepth is how deep records (a user, a chat, ...) sit in a document:
+// top level, then section, then record.
+const recordDepth = 2
+
+// mergeJSON merges one compacted JSON value at the given depth; nil means
+// absent. See mergeDocs.
+func mergeJSON(base, ours, theirs json.RawMessage, depth int) (json.RawMessage, error) {
+	// Inside a record a missing object is usually an emptied omitempty map
+	// (the last place deleted, say), so merge it as {} rather than let the
+	// removal wipe out the other side's additions. Whole records stay deletable.
+	if depth > recordDepth && isObject(base) {
+		if ours == nil {
+			ours = json.RawMessage("{}")
+		}
+		if theirs == nil {
+			theirs = json.RawMessage("{}")
+		}
+	}
+
+	switch {
+	case bytes.Equal(base, ours):
+		return theirs, nil
+	case bytes.Equal(base, theirs), !isObject(ours) || !isObject(theirs):
+		return ours, nil
+	}
+
+	// Both sides changed an object: merge it key by key.
+	var b, o, t map[string]json.RawMessage
+	if isObject(base) {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(ours, &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(theirs, &t); err != nil {
+		return nil, err
+	}
+	merged := make(map[string]json.RawMessage, len(t))
+	for _, side := range []map[string]json.RawMessage{b, o, t} {
+		for key := range side {
+			if _, done := merged[key]; done {
+				continue
+			}
+			value, err := mergeJSON(b[key], o[key], t[key], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			merged[key] = value
+		}
+	}
+	for key, value := range merged {
+		if value == nil || (depth >= recordDepth && string(value) == "{}") {
+			delete(merged, key)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+func isObject(v json.RawMessage) bool {
+	return len(v) > 0 && v[0] == '{'
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupTarget is where encrypted store snapshots are kept.
+// Names are flat object names such as "prefs-20260101T070000Z.json.enc".
+type BackupTarget interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+const (
+	backupPrefix = "prefs-"
+	backupSuffix = ".json.enc"
+	backupLayout = "20060102T150405Z"
+)
+
+// Backups periodically writes encrypted snapshots of a store to a BackupTarget
+// and prunes old ones. Snapshots are sealed with AES-256-GCM, so the object storage
+// provider never sees user data in the clear.
+type Backups struct {
+	store    Snapshotter
+	target   BackupTarget
+	aead     cipher.AEAD
+	retain   int           // number of snapshots to keep
+	interval time.Duration // minimum time between snapshots for Tick
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewBackups creates a backup runner. key must be 32 bytes (AES-256).
+// retain is the number of snapshots kept; interval is how often Tick takes one.
+func NewBackups(s Snapshotter, target BackupTarget, key []byte, retain int, interval time.Duration) (*Backups, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("backup key must be 32 bytes, got %d", len(key))
+	}
+	if retain < 1 {
+		return nil, errors.New("backup retention must keep at least one snapshot")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+
+	return &Backups{
+		store:    s,
+		target:   target,
+		aead:     aead,
+		retain:   retain,
+		interval: interval,
+	}, nil
+}
+
+// Tick takes a snapshot when interval has elapsed since the last one.
+// Its signature matches scheduler.Job so it can be registered directly.
+func (b *Backups) Tick(ctx context.Context, now time.Time) {
+	b.mu.Lock()
+	due := b.last.IsZero() || now.Sub(b.last) >= b.interval
+	b.mu.Unlock()
+	if !due {
+		return
+	}
+
+	name, err := b.Run(ctx, now)
+	if err != nil {
+		log.Printf("backup: %v", err)
+		return
+	}
+	log.Printf("backup: wrote %s", name)
+}
+
+// Run writes one snapshot immediately, then applies the retention policy.
+// It returns the name of the new snapshot.
+func (b *Backups) Run(ctx context.Context, now time.Time) (string, error) {
+	plain, err := b.store.Snapshot()
+	if err != nil {
+		return "", fmt.Errorf("snapshot store: %w", err)
+	}
+
+	sealed, err := b.seal(plain)
+	if err != nil {
+		return "", err
+	}
+
+	name := backupPrefix + now.UTC().Format(backupLayout) + backupSuffix
+	if err := b.target.Put(ctx, name, sealed); err != nil {
+		return "", fmt.Errorf("put %s: %w", name, err)
+	}
+
+	b.mu.Lock()
+	b.last = now
+	b.mu.Unlock()
+
+	if err := b.prune(ctx); err != nil {
+		// The new snapshot is safe; a failed prune only costs storage.
+		log.Printf("backup: prune: %v", err)
+	}
+	return name, nil
+}
+
+// List returns snapshot names, oldest first.
+func (b *Backups) List(ctx context.Context) ([]string, error) {
+	names, err := b.target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []string
+	for _, n := range names {
+		if strings.HasPrefix(n, backupPrefix) && strings.HasSuffix(n, backupSuffix) {
+			snapshots = append(snapshots, n)
+		}
+	}
+	// The timestamp layout sorts lexically in time order.
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// Restore decrypts the named snapshot and replaces the store's state with it.
+func (b *Backups) Restore(ctx context.Context, name string) error {
+	sealed, err := b.target.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", name, err)
+	}
+
+	plain, err := b.open(sealed)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", name, err)
+	}
+
+	return b.store.Restore(plain)
+}
+
+// RestoreLatest restores the newest snapshot and returns its name.
+func (b *Backups) RestoreLatest(ctx context.Context) (string, error) {
+	names, err := b.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", errors.New("no backups found")
+	}
+
+	latest := names[len(names)-1]
+	return latest, b.Restore(ctx, latest)
+}
+
+// prune deletes the oldest snapshots beyond the retention count.
+func (b *Backups) prune(ctx context.Context) error {
+	names, err := b.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for len(names) > b.retain {
+		if err := b.target.Delete(ctx, names[0]); err != nil {
+			return fmt.Errorf("delete %s: %w", names[0], err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// seal encrypts plain, prefixing the random nonce.
+func (b *Backups) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// open reverses seal.
+func (b *Backups) open(sealed []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return b.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// DirTarget keeps backups as files in a local directory
+// (a mounted volume, or a directory synced elsewhere by other tooling).
+type DirTarget struct {
+	Dir string
+}
+
+// Put writes data to Dir/name.
+func (d DirTarget) Put(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(d.Dir, 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.Dir, filepath.Base(name)), data, 0o600)
+}
+
+// Get reads Dir/name.
+func (d DirTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(d.Dir, filepath.Base(name)))
+}
+
+// List returns the file names in Dir.
+func (d DirTarget) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete removes Dir/name.
+func (d DirTarget) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.Dir, filepath.Base(name)))
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Ban blocks userID from using the bot until Unban is called.
+func (s *UserStore) Ban(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.banned[userID] = true
+	return s.saveToFile()
+}
+
+// Unban lifts a ban set by Ban.
+func (s *UserStore) Unban(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.banned[userID] {
+		return fmt.Errorf("user %d is not banned", userID)
+	}
+	delete(s.banned, userID)
+	return s.saveToFile()
+}
+
+// IsBanned reports whether userID was banned with Ban.
+func (s *UserStore) IsBanned(userID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.banned[userID]
+}
+
+// Banned returns the banned user IDs in ascending order.
+func (s *UserStore) Banned() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.bannedLocked()
+}
+
+func (s *UserStore) bannedLocked() []int64 {
+	ids := make([]int64, 0, len(s.banned))
+	for id := range s.banned {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// benchStore returns a file-backed store with n users, each with stops, a
+// place, a walk time and a subscription, roughly what an active user has.
+func benchStore(b *testing.B, n int) *UserStore {
+	b.Helper()
+	s := NewUserStore(filepath.Join(b.TempDir(), "prefs.json"))
+	s.file = "" // fill in memory, then save once
+	for i := 0; i < n; i++ {
+		userID := int64(100000 + i)
+		_ = s.SetHome(userID, fmt.Sprint(3000+i%500))
+		_ = s.SetWork(userID, fmt.Sprint(9000+i%500))
+		_ = s.SetPlace(userID, "gym", SavedPlace{SiteID: 1000 + i%50, SiteName: "Frösunda torg"})
+		_ = s.SetWalkTime(userID, fmt.Sprint(3000+i%500), 5)
+		s.userLocked(userID).Subscriptions = []Subscription{{ID: 1, ChatID: userID, SiteID: 3484,
+			SiteName: "Storgatan", Start: 420, End: 480, Days: "weekdays", LastSent: "2025-12-27"}}
+	}
+	s.file = filepath.Join(b.TempDir(), "prefs.json")
+	if err := s.writeLocked(); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// BenchmarkSave measures one preference change on a store with 10k users,
+// which rewrites the whole file.
+func BenchmarkSave(b *testing.B) {
+	s := benchStore(b, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.SetLateAfter(100000, time.Duration(i%60)*time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSaveLocked is BenchmarkSave with file locking, which re-reads and
+// merges the file on every save.
+func BenchmarkSaveLocked(b *testing.B) {
+	s := benchStore(b, 10000)
+	s.SetFileLocking(true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.SetLateAfter(100000, time.Duration(i%60)*time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLoad measures starting up from a file with 10k users.
+func BenchmarkLoad(b *testing.B) {
+	file := benchStore(b, 10000).file
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if s := NewUserStore(file); len(s.prefs) != 10000 {
+			b.Fatalf("loaded %d users, want 10000", len(s.prefs))
+		}
+	}
+}
+
+func BenchmarkGetPrefs(b *testing.B) {
+	s := benchStore(b, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GetPrefs(int64(100000 + i%10000))
+	}
+}
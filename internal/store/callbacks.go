@@ -0,0 +1,29 @@
+package store
+
+import (
+	"time"
+)
+
+// MarkCallback records a Telegram callback query ID as processed for ttl.
+// It returns false if the ID was already recorded and hasn't expired, meaning the
+// caller is looking at a redelivery and should not act on it again. The check and
+// the insert happen under one lock, so concurrent duplicates are caught too.
+func (s *UserStore) MarkCallback(id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expires, seen := s.callbacks[id]; seen && now.Before(expires) {
+		return false, nil
+	}
+
+	// Prune expired IDs so the map (and the file) stay small.
+	for cbID, expires := range s.callbacks {
+		if !now.Before(expires) {
+			delete(s.callbacks, cbID)
+		}
+	}
+	s.callbacks[id] = now.Add(ttl)
+
+	return true, s.saveToFile()
+}
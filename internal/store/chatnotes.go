@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxNotesPerChat bounds how many stop notes a single chat can accumulate.
+const maxNotesPerChat = 50
+
+// ChatState holds per-chat data shared by everyone in that chat.
+type ChatState struct {
+	NotesShared bool       `json:"notesShared"` // group chats must opt in before notes are shown
+	Notes       []StopNote `json:"notes,omitempty"`
+}
+
+// StopNote is a short tip attached to a stop, optionally narrowed to one line.
+type StopNote struct {
+	SiteID   int       `json:"siteId"`
+	Line     string    `json:"line,omitempty"` // empty means "any line at this stop"
+	Text     string    `json:"text"`
+	AuthorID int64     `json:"authorId"`
+	Created  time.Time `json:"created"`
+}
+
+// AddNote appends a stop note to a chat.
+func (s *UserStore) AddNote(chatID int64, note StopNote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat := s.chatLocked(chatID)
+	if len(chat.Notes) >= maxNotesPerChat {
+		return fmt.Errorf("chat %d already has %d notes", chatID, maxNotesPerChat)
+	}
+	chat.Notes = append(chat.Notes, note)
+
+	return s.saveToFile()
+}
+
+// DeleteNote removes the note at index (0-based, as returned by Notes).
+func (s *UserStore) DeleteNote(chatID int64, index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, exists := s.chats[chatID]
+	if !exists || index < 0 || index >= len(chat.Notes) {
+		return fmt.Errorf("note %d not found", index+1)
+	}
+	chat.Notes = append(chat.Notes[:index], chat.Notes[index+1:]...)
+
+	return s.saveToFile()
+}
+
+// Notes returns a copy of all notes stored for a chat.
+func (s *UserStore) Notes(chatID int64) []StopNote {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, exists := s.chats[chatID]
+	if !exists {
+		return nil
+	}
+	return append([]StopNote(nil), chat.Notes...)
+}
+
+// NotesShared reports whether a chat has opted in to showing shared notes.
+func (s *UserStore) NotesShared(chatID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, exists := s.chats[chatID]
+	return exists && chat.NotesShared
+}
+
+// SetNotesShared toggles the shared-notes opt-in for a chat.
+func (s *UserStore) SetNotesShared(chatID int64, shared bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chatLocked(chatID).NotesShared = shared
+
+	return s.saveToFile()
+}
+
+// chatLocked returns the state for chatID, creating it if needed.
+// The caller must hold s.mu for writing.
+func (s *UserStore) chatLocked(chatID int64) *ChatState {
+	chat, exists := s.chats[chatID]
+	if !exists {
+		chat = &ChatState{}
+		s.chats[chatID] = chat
+	}
+	return chat
+}
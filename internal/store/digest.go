@@ -0,0 +1,52 @@
+package store
+
+import "fmt"
+
+// Digest is a user's daily commute digest: the board towards work and any
+// disruptions at their stops, pushed once a day at At.
+type Digest struct {
+	ChatID   int64  `json:"chatId"`
+	At       int    `json:"at"`       // minutes after midnight, Stockholm time
+	Days     string `json:"days"`     // "daily", "weekdays" or "weekends"
+	LastSent string `json:"lastSent"` // day key of the last push, "" if never
+}
+
+// SetDigest turns the user's digest on, or off when digest is nil.
+func (s *UserStore) SetDigest(userID int64, digest *Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if digest != nil {
+		d := *digest
+		digest = &d
+	}
+	s.userLocked(userID).Digest = digest
+	return s.saveToFile()
+}
+
+// AllDigests returns a snapshot of every enabled digest, keyed by userID.
+func (s *UserStore) AllDigests() map[int64]Digest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[int64]Digest)
+	for userID, prefs := range s.prefs {
+		if prefs.Digest != nil {
+			all[userID] = *prefs.Digest
+		}
+	}
+	return all
+}
+
+// MarkDigestSent records that the user's digest was pushed on day.
+func (s *UserStore) MarkDigestSent(userID int64, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists || prefs.Digest == nil {
+		return fmt.Errorf("user %d has no digest", userID)
+	}
+	prefs.Digest.LastSent = day
+	return s.saveToFile()
+}
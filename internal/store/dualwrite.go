@@ -0,0 +1,428 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DualWrite runs two stores side by side while migrating between backends,
+// e.g. from the JSON file to SQLite. Every write goes to both; reads are served
+// by the primary and, with compareReads, also made against the secondary and
+// logged when the answers differ. After a quiet soak period, cut over by making
+// the secondary the primary (or dropping DualWrite altogether).
+//
+//	var st store.Store = store.NewDualWrite(fileStore, sqliteStore, true)
+//
+// or let STORE_DUAL_WRITE decide, see DualWriteFromEnv.
+//
+// Writes that fail on the primary are not mirrored; failures on the secondary
+// are logged and counted but never returned, so the bot behaves exactly as it
+// would on the primary alone. To start from the same state, Restore the
+// secondary from a primary Snapshot before wrapping them.
+type DualWrite struct {
+	primary, secondary Store
+	compareReads       bool
+
+	divergences     atomic.Int64
+	secondaryErrors atomic.Int64
+}
+
+// DualWrite must keep satisfying Store.
+var _ Store = (*DualWrite)(nil)
+
+// NewDualWrite wraps primary and secondary; see DualWrite.
+func NewDualWrite(primary, secondary Store, compareReads bool) *DualWrite {
+	return &DualWrite{primary: primary, secondary: secondary, compareReads: compareReads}
+}
+
+// DualWriteFromEnv wraps primary and secondary according to STORE_DUAL_WRITE,
+// so a soak can be started and stopped without a code change:
+//
+//	STORE_DUAL_WRITE=off (default)  primary only; secondary is left unused
+//	STORE_DUAL_WRITE=write          writes go to both, reads to the primary
+//	STORE_DUAL_WRITE=compare        as write, and reads are compared too
+//
+// A nil secondary or an unknown value also gives the primary alone.
+func DualWriteFromEnv(primary, secondary Store) Store {
+	if secondary == nil {
+		return primary
+	}
+	switch v := os.Getenv("STORE_DUAL_WRITE"); v {
+	case "write":
+		return NewDualWrite(primary, secondary, false)
+	case "compare":
+		return NewDualWrite(primary, secondary, true)
+	case "", "off":
+	default:
+		log.Printf("DualWriteFromEnv: unknown STORE_DUAL_WRITE=%q, using the primary only", v)
+	}
+	return primary
+}
+
+// DualWriteStats counts problems seen since the DualWrite was created.
+type DualWriteStats struct {
+	Divergences     int64 // reads or write results where the backends disagreed
+	SecondaryErrors int64 // writes that failed only on the secondary
+}
+
+// Stats returns the counters; both should stay at zero before a cutover.
+func (d *DualWrite) Stats() DualWriteStats {
+	return DualWriteStats{
+		Divergences:     d.divergences.Load(),
+		SecondaryErrors: d.secondaryErrors.Load(),
+	}
+}
+
+// mirror applies a write to the secondary once it has succeeded on the primary.
+func (d *DualWrite) mirror(op string, primaryErr error, secondary func() error) error {
+	if primaryErr != nil {
+		return primaryErr
+	}
+	if err := secondary(); err != nil {
+		d.secondaryErrors.Add(1)
+		log.Printf("DualWrite: %s failed on secondary: %v", op, err)
+	}
+	return nil
+}
+
+// diverged records a disagreement between the backends.
+func (d *DualWrite) diverged(op string, primary, secondary any) {
+	d.divergences.Add(1)
+	log.Printf("DualWrite: %s diverges: primary=%+v secondary=%+v", op, primary, secondary)
+}
+
+// compareRead returns the primary's answer, checking it against the
+// secondary's when read comparison is on.
+func compareRead[T any](d *DualWrite, op string, primary T, secondary func() T) T {
+	if d.compareReads {
+		if other := secondary(); !sameAnswer(primary, other) {
+			d.diverged(op, primary, other)
+		}
+	}
+	return primary
+}
+
+// sameAnswer reports whether two reads hold the same data, ignoring what
+// backends legitimately differ on: nil versus empty slices and maps, and the
+// location and monotonic reading of times. Both are compared as their JSON,
+// normalised by normalizeAnswer.
+func sameAnswer(a, b any) bool {
+	na, errA := normalizeAnswer(a)
+	nb, errB := normalizeAnswer(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+// normalizeAnswer decodes v's JSON encoding into plain values with empty
+// arrays, objects and nulls dropped (nil at the top) and timestamps in UTC.
+func normalizeAnswer(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var plain any
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil, err
+	}
+	return normalizeValue(plain), nil
+}
+
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if value = normalizeValue(value); value == nil {
+				delete(v, key)
+			} else {
+				v[key] = value
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return v
+}
+
+// Preferences
+
+func (d *DualWrite) GetPrefs(userID int64) UserPreferences {
+	return compareRead(d, fmt.Sprintf("GetPrefs(%d)", userID), d.primary.GetPrefs(userID),
+		func() UserPreferences { return d.secondary.GetPrefs(userID) })
+}
+
+func (d *DualWrite) SetHome(userID int64, siteID string) error {
+	return d.mirror("SetHome", d.primary.SetHome(userID, siteID),
+		func() error { return d.secondary.SetHome(userID, siteID) })
+}
+
+func (d *DualWrite) SetWork(userID int64, siteID string) error {
+	return d.mirror("SetWork", d.primary.SetWork(userID, siteID),
+		func() error { return d.secondary.SetWork(userID, siteID) })
+}
+
+func (d *DualWrite) SetPlace(userID int64, alias string, place SavedPlace) error {
+	return d.mirror("SetPlace", d.primary.SetPlace(userID, alias, place),
+		func() error { return d.secondary.SetPlace(userID, alias, place) })
+}
+
+func (d *DualWrite) DeletePlace(userID int64, alias string) error {
+	return d.mirror("DeletePlace", d.primary.DeletePlace(userID, alias),
+		func() error { return d.secondary.DeletePlace(userID, alias) })
+}
+
+func (d *DualWrite) SetDirection(userID int64, siteID string, directionCode int) error {
+	return d.mirror("SetDirection", d.primary.SetDirection(userID, siteID, directionCode),
+		func() error { return d.secondary.SetDirection(userID, siteID, directionCode) })
+}
+
+func (d *DualWrite) SetWalkTime(userID int64, siteID string, minutes int) error {
+	return d.mirror("SetWalkTime", d.primary.SetWalkTime(userID, siteID, minutes),
+		func() error { return d.secondary.SetWalkTime(userID, siteID, minutes) })
+}
+
+func (d *DualWrite) SetLateAfter(userID int64, lateAfter time.Duration) error {
+	return d.mirror("SetLateAfter", d.primary.SetLateAfter(userID, lateAfter),
+		func() error { return d.secondary.SetLateAfter(userID, lateAfter) })
+}
+
+func (d *DualWrite) SetLayout(userID int64, layout string) error {
+	return d.mirror("SetLayout", d.primary.SetLayout(userID, layout),
+		func() error { return d.secondary.SetLayout(userID, layout) })
+}
+
+func (d *DualWrite) SetWebhookURL(userID int64, webhookURL string) error {
+	return d.mirror("SetWebhookURL", d.primary.SetWebhookURL(userID, webhookURL),
+		func() error { return d.secondary.SetWebhookURL(userID, webhookURL) })
+}
+
+func (d *DualWrite) SetNotification(userID int64, category NotificationCategory, enabled bool) error {
+	return d.mirror("SetNotification", d.primary.SetNotification(userID, category, enabled),
+		func() error { return d.secondary.SetNotification(userID, category, enabled) })
+}
+
+func (d *DualWrite) RecordPush(userID int64, rec PushRecord) error {
+	return d.mirror("RecordPush", d.primary.RecordPush(userID, rec),
+		func() error { return d.secondary.RecordPush(userID, rec) })
+}
+
+func (d *DualWrite) PushHistory(userID int64) []PushRecord {
+	return compareRead(d, fmt.Sprintf("PushHistory(%d)", userID), d.primary.PushHistory(userID),
+		func() []PushRecord { return d.secondary.PushHistory(userID) })
+}
+
+// Subscriptions
+
+// AddSubscription also compares the stored subscriptions, since each backend
+// assigns the ID itself.
+func (d *DualWrite) AddSubscription(userID int64, sub Subscription) (Subscription, error) {
+	added, err := d.primary.AddSubscription(userID, sub)
+	err = d.mirror("AddSubscription", err, func() error {
+		other, err := d.secondary.AddSubscription(userID, sub)
+		if err == nil && !sameAnswer(added, other) {
+			d.diverged("AddSubscription", added, other)
+		}
+		return err
+	})
+	return added, err
+}
+
+func (d *DualWrite) RemoveSubscription(userID int64, id int) error {
+	return d.mirror("RemoveSubscription", d.primary.RemoveSubscription(userID, id),
+		func() error { return d.secondary.RemoveSubscription(userID, id) })
+}
+
+func (d *DualWrite) AllSubscriptions() map[int64][]Subscription {
+	return compareRead(d, "AllSubscriptions", d.primary.AllSubscriptions(),
+		func() map[int64][]Subscription { return d.secondary.AllSubscriptions() })
+}
+
+func (d *DualWrite) MarkSubscriptionSent(userID int64, id int, day string) error {
+	return d.mirror("MarkSubscriptionSent", d.primary.MarkSubscriptionSent(userID, id, day),
+		func() error { return d.secondary.MarkSubscriptionSent(userID, id, day) })
+}
+
+// Deviation alerts
+
+func (d *DualWrite) AddDeviationAlert(userID int64, alert DeviationAlert) (DeviationAlert, error) {
+	added, err := d.primary.AddDeviationAlert(userID, alert)
+	err = d.mirror("AddDeviationAlert", err, func() error {
+		other, err := d.secondary.AddDeviationAlert(userID, alert)
+		if err == nil && !sameAnswer(added, other) {
+			d.diverged("AddDeviationAlert", added, other)
+		}
+		return err
+	})
+	return added, err
+}
+
+func (d *DualWrite) RemoveDeviationAlert(userID int64, id int) error {
+	return d.mirror("RemoveDeviationAlert", d.primary.RemoveDeviationAlert(userID, id),
+		func() error { return d.secondary.RemoveDeviationAlert(userID, id) })
+}
+
+func (d *DualWrite) AllDeviationAlerts() map[int64][]DeviationAlert {
+	return compareRead(d, "AllDeviationAlerts", d.primary.AllDeviationAlerts(),
+		func() map[int64][]DeviationAlert { return d.secondary.AllDeviationAlerts() })
+}
+
+func (d *DualWrite) MarkDeviationSent(userID int64, id int, key string, now time.Time) error {
+	return d.mirror("MarkDeviationSent", d.primary.MarkDeviationSent(userID, id, key, now),
+		func() error { return d.secondary.MarkDeviationSent(userID, id, key, now) })
+}
+
+// Daily digests
+
+func (d *DualWrite) SetDigest(userID int64, digest *Digest) error {
+	return d.mirror("SetDigest", d.primary.SetDigest(userID, digest),
+		func() error { return d.secondary.SetDigest(userID, digest) })
+}
+
+func (d *DualWrite) AllDigests() map[int64]Digest {
+	return compareRead(d, "AllDigests", d.primary.AllDigests(),
+		func() map[int64]Digest { return d.secondary.AllDigests() })
+}
+
+func (d *DualWrite) MarkDigestSent(userID int64, day string) error {
+	return d.mirror("MarkDigestSent", d.primary.MarkDigestSent(userID, day),
+		func() error { return d.secondary.MarkDigestSent(userID, day) })
+}
+
+func (d *DualWrite) Limits() Limits {
+	return compareRead(d, "Limits", d.primary.Limits(), func() Limits { return d.secondary.Limits() })
+}
+
+func (d *DualWrite) SetLimits(limits Limits) error {
+	return d.mirror("SetLimits", d.primary.SetLimits(limits),
+		func() error { return d.secondary.SetLimits(limits) })
+}
+
+// Per-chat state
+
+func (d *DualWrite) AddNote(chatID int64, note StopNote) error {
+	return d.mirror("AddNote", d.primary.AddNote(chatID, note),
+		func() error { return d.secondary.AddNote(chatID, note) })
+}
+
+func (d *DualWrite) DeleteNote(chatID int64, index int) error {
+	return d.mirror("DeleteNote", d.primary.DeleteNote(chatID, index),
+		func() error { return d.secondary.DeleteNote(chatID, index) })
+}
+
+func (d *DualWrite) Notes(chatID int64) []StopNote {
+	return compareRead(d, fmt.Sprintf("Notes(%d)", chatID), d.primary.Notes(chatID),
+		func() []StopNote { return d.secondary.Notes(chatID) })
+}
+
+func (d *DualWrite) NotesShared(chatID int64) bool {
+	return compareRead(d, fmt.Sprintf("NotesShared(%d)", chatID), d.primary.NotesShared(chatID),
+		func() bool { return d.secondary.NotesShared(chatID) })
+}
+
+func (d *DualWrite) SetNotesShared(chatID int64, shared bool) error {
+	return d.mirror("SetNotesShared", d.primary.SetNotesShared(chatID, shared),
+		func() error { return d.secondary.SetNotesShared(chatID, shared) })
+}
+
+// Access control and guests
+
+func (d *DualWrite) Ban(userID int64) error {
+	return d.mirror("Ban", d.primary.Ban(userID), func() error { return d.secondary.Ban(userID) })
+}
+
+func (d *DualWrite) Unban(userID int64) error {
+	return d.mirror("Unban", d.primary.Unban(userID), func() error { return d.secondary.Unban(userID) })
+}
+
+func (d *DualWrite) IsBanned(userID int64) bool {
+	return compareRead(d, fmt.Sprintf("IsBanned(%d)", userID), d.primary.IsBanned(userID),
+		func() bool { return d.secondary.IsBanned(userID) })
+}
+
+func (d *DualWrite) Banned() []int64 {
+	return compareRead(d, "Banned", d.primary.Banned(), func() []int64 { return d.secondary.Banned() })
+}
+
+// CreateGuestToken creates the token on the primary only: token IDs are random
+// per backend, so the secondary couldn't redeem the ID handed out. Redemptions
+// are mirrored as the saved place they produce instead.
+func (d *DualWrite) CreateGuestToken(guest GuestToken) (string, error) {
+	return d.primary.CreateGuestToken(guest)
+}
+
+// RedeemGuestToken redeems on the primary and saves the resulting place on the secondary.
+func (d *DualWrite) RedeemGuestToken(id string, guestID int64) (GuestToken, error) {
+	t, err := d.primary.RedeemGuestToken(id, guestID)
+	err = d.mirror("RedeemGuestToken", err, func() error {
+		return d.secondary.SetPlace(guestID, t.Label, SavedPlace{SiteID: t.SiteID, SiteName: t.SiteName, Expires: t.Expires})
+	})
+	return t, err
+}
+
+// Inline button state
+
+func (d *DualWrite) SetMessageContext(chatID int64, messageID int, query string, ttl time.Duration) error {
+	return d.mirror("SetMessageContext", d.primary.SetMessageContext(chatID, messageID, query, ttl),
+		func() error { return d.secondary.SetMessageContext(chatID, messageID, query, ttl) })
+}
+
+func (d *DualWrite) MessageContext(chatID int64, messageID int) (string, bool) {
+	query, ok := d.primary.MessageContext(chatID, messageID)
+	if d.compareReads {
+		if q2, ok2 := d.secondary.MessageContext(chatID, messageID); q2 != query || ok2 != ok {
+			d.diverged(fmt.Sprintf("MessageContext(%d, %d)", chatID, messageID), query, q2)
+		}
+	}
+	return query, ok
+}
+
+// Update bookkeeping
+
+func (d *DualWrite) MarkCallback(id string, ttl time.Duration) (bool, error) {
+	first, err := d.primary.MarkCallback(id, ttl)
+	err = d.mirror("MarkCallback", err, func() error {
+		other, err := d.secondary.MarkCallback(id, ttl)
+		if err == nil && other != first {
+			d.diverged("MarkCallback", first, other)
+		}
+		return err
+	})
+	return first, err
+}
+
+func (d *DualWrite) LastUpdateID() int {
+	return compareRead(d, "LastUpdateID", d.primary.LastUpdateID(), func() int { return d.secondary.LastUpdateID() })
+}
+
+func (d *DualWrite) SetLastUpdateID(id int) error {
+	return d.mirror("SetLastUpdateID", d.primary.SetLastUpdateID(id),
+		func() error { return d.secondary.SetLastUpdateID(id) })
+}
+
+// Snapshot exports the primary.
+func (d *DualWrite) Snapshot() ([]byte, error) {
+	return d.primary.Snapshot()
+}
+
+// Restore restores both backends from the same snapshot.
+func (d *DualWrite) Restore(data []byte) error {
+	return d.mirror("Restore", d.primary.Restore(data), func() error { return d.secondary.Restore(data) })
+}
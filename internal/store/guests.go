@@ -0,0 +1,74 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// GuestToken lets whoever holds it borrow a user's home stop as a temporary
+// saved place until Expires. A token can be redeemed by several guests.
+type GuestToken struct {
+	OwnerID  int64     `json:"ownerId"`
+	Label    string    `json:"label"` // alias guests get, e.g. the owner's first name
+	SiteID   int       `json:"siteId"`
+	SiteName string    `json:"siteName"`
+	Expires  time.Time `json:"expires"`
+}
+
+// CreateGuestToken stores a new guest token and returns its ID, which is safe to
+// use as a Telegram /start payload. Expired tokens are pruned on the way.
+func (s *UserStore) CreateGuestToken(guest GuestToken) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for existing, t := range s.guests {
+		if now.After(t.Expires) {
+			delete(s.guests, existing)
+		}
+	}
+	s.guests[id] = &guest
+
+	return id, s.saveToFile()
+}
+
+// RedeemGuestToken adds the token's stop to guestID's places, expiring with the
+// token, and returns the token. The place is named after the token's label; if
+// guestID already has a place by that name, a number is added ("anna2") rather
+// than replace it. The returned token's Label is the name used.
+func (s *UserStore) RedeemGuestToken(id string, guestID int64) (GuestToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	t, ok := s.guests[id]
+	if !ok || now.After(t.Expires) {
+		return GuestToken{}, fmt.Errorf("guest link not found or expired")
+	}
+
+	prefs := s.userLocked(guestID)
+	if prefs.Places == nil {
+		prefs.Places = make(map[string]SavedPlace)
+	}
+	// An expired place, or this link redeemed before, may be replaced.
+	redeemed := *t
+	for n := 2; ; n++ {
+		existing, taken := prefs.Places[redeemed.Label]
+		expired := !existing.Expires.IsZero() && now.After(existing.Expires)
+		if !taken || expired || (existing.SiteID == t.SiteID && existing.Expires.Equal(t.Expires)) {
+			break
+		}
+		redeemed.Label = fmt.Sprintf("%s%d", t.Label, n)
+	}
+	prefs.Places[redeemed.Label] = SavedPlace{SiteID: t.SiteID, SiteName: t.SiteName, Expires: t.Expires}
+
+	return redeemed, s.saveToFile()
+}
@@ -0,0 +1,52 @@
+package store
+
+import "time"
+
+// History bounds: the last maxHistoryPerUser pushes are kept per user, each
+// with at most maxHistoryText runes of its text.
+const (
+	maxHistoryPerUser = 20
+	maxHistoryText    = 300
+)
+
+// PushRecord is one message the bot sent without being asked (a briefing or an
+// alert), kept so users can check what they were told and whether it arrived.
+type PushRecord struct {
+	Sent      time.Time `json:"sent"`
+	Kind      string    `json:"kind"` // e.g. "briefing"
+	ChatID    int64     `json:"chatId"`
+	Text      string    `json:"text"` // truncated to maxHistoryText runes
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"` // why delivery failed
+}
+
+// RecordPush appends rec to userID's history, dropping the oldest record past
+// maxHistoryPerUser.
+func (s *UserStore) RecordPush(userID int64, rec PushRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := []rune(rec.Text); len(r) > maxHistoryText {
+		rec.Text = string(r[:maxHistoryText]) + "…"
+	}
+
+	prefs := s.userLocked(userID)
+	prefs.History = append(prefs.History, rec)
+	if n := len(prefs.History); n > maxHistoryPerUser {
+		prefs.History = append([]PushRecord(nil), prefs.History[n-maxHistoryPerUser:]...)
+	}
+
+	return s.saveToFile()
+}
+
+// PushHistory returns a copy of userID's push history, oldest first.
+func (s *UserStore) PushHistory(userID int64) []PushRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return nil
+	}
+	return append([]PushRecord(nil), prefs.History...)
+}
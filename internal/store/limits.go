@@ -0,0 +1,71 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits caps how much scheduled work users can register, since every
+// subscription and alert is polled by the scheduler. Zero means unlimited.
+type Limits struct {
+	SubscriptionsPerUser   int `json:"subscriptionsPerUser"`
+	SubscriptionsTotal     int `json:"subscriptionsTotal"`
+	DeviationAlertsPerUser int `json:"deviationAlertsPerUser"`
+	AlarmsPerUser          int `json:"alarmsPerUser"` // departure alarms, which the bot keeps in memory
+}
+
+// DefaultLimits applies until an admin changes them with SetLimits. Limits
+// added since a file was saved take their default when it is loaded.
+var DefaultLimits = Limits{
+	SubscriptionsPerUser:   10,
+	SubscriptionsTotal:     1000,
+	DeviationAlertsPerUser: 10,
+	AlarmsPerUser:          5,
+}
+
+// Errors returned (wrapped) when a limit is hit, so callers can explain which one.
+var (
+	ErrUserLimit   = errors.New("per-user limit reached")
+	ErrGlobalLimit = errors.New("global limit reached")
+)
+
+// Limits returns the current limits.
+func (s *UserStore) Limits() Limits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.limits
+}
+
+// SetLimits replaces the limits and persists them. Existing subscriptions above
+// a lowered limit are kept; only new ones are refused.
+func (s *UserStore) SetLimits(limits Limits) error {
+	if limits.SubscriptionsPerUser < 0 || limits.SubscriptionsTotal < 0 || limits.DeviationAlertsPerUser < 0 || limits.AlarmsPerUser < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits = limits
+	return s.saveToFile()
+}
+
+// checkSubscriptionLimitsLocked reports whether userID may add one more subscription.
+func (s *UserStore) checkSubscriptionLimitsLocked(userID int64) error {
+	total, mine := 0, 0
+	for id, prefs := range s.prefs {
+		total += len(prefs.Subscriptions)
+		if id == userID {
+			mine = len(prefs.Subscriptions)
+		}
+	}
+
+	if max := s.limits.SubscriptionsPerUser; max > 0 && mine >= max {
+		return fmt.Errorf("%w: user %d has %d of %d subscriptions", ErrUserLimit, userID, mine, max)
+	}
+	if max := s.limits.SubscriptionsTotal; max > 0 && total >= max {
+		return fmt.Errorf("%w: %d of %d subscriptions in use", ErrGlobalLimit, total, max)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package store
+
+// lockFile is a no-op where flock isn't available; saves are still atomic.
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
+
+// syncDir is a no-op where directories can't be synced (e.g. Windows).
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxMessageContexts caps how many message contexts are kept; the oldest-expiring go first.
+const maxMessageContexts = 5000
+
+// MessageContext is the query behind a bot message, kept so inline buttons on
+// that message (refresh, reverse, ...) can repeat it. Telegram callback data is
+// limited to 64 bytes, so buttons carry only an action and look this up by
+// (chat, message).
+type MessageContext struct {
+	Query   string    `json:"q"` // compact, caller-defined encoding of the query
+	Expires time.Time `json:"exp"`
+}
+
+// SetMessageContext remembers query for the message messageID in chatID for ttl.
+// Expired contexts are pruned on every call.
+func (s *UserStore) SetMessageContext(chatID int64, messageID int, query string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.pruneMessagesLocked(now)
+	s.messages[messageKey(chatID, messageID)] = &MessageContext{Query: query, Expires: now.Add(ttl)}
+
+	return s.saveToFile()
+}
+
+// MessageContext returns the query stored for a message, if it hasn't expired.
+func (s *UserStore) MessageContext(chatID int64, messageID int) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mc, ok := s.messages[messageKey(chatID, messageID)]
+	if !ok || !time.Now().Before(mc.Expires) {
+		return "", false
+	}
+	return mc.Query, true
+}
+
+// pruneMessagesLocked drops expired contexts and, if still over the cap, the
+// ones closest to expiry.
+func (s *UserStore) pruneMessagesLocked(now time.Time) {
+	for key, mc := range s.messages {
+		if !now.Before(mc.Expires) {
+			delete(s.messages, key)
+		}
+	}
+	for len(s.messages) >= maxMessageContexts {
+		var oldest string
+		for key, mc := range s.messages {
+			if oldest == "" || mc.Expires.Before(s.messages[oldest].Expires) {
+				oldest = key
+			}
+		}
+		delete(s.messages, oldest)
+	}
+}
+
+func messageKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d:%d", chatID, messageID)
+}
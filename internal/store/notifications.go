@@ -0,0 +1,57 @@
+package store
+
+import "fmt"
+
+// NotificationCategory names a kind of message the bot sends unprompted.
+type NotificationCategory string
+
+// Notification categories users can switch off individually.
+const (
+	NotifyBriefings     NotificationCategory = "briefings"      // scheduled departure boards, see Subscription
+	NotifyServiceAlerts NotificationCategory = "service_alerts" // SL outage and recovery notices
+	NotifyDisruptions   NotificationCategory = "disruptions"    // new deviations, see DeviationAlert
+)
+
+// NotificationCategories lists every category in display order.
+var NotificationCategories = []NotificationCategory{NotifyBriefings, NotifyDisruptions, NotifyServiceAlerts}
+
+// NotificationPreference holds a user's per-category notification toggles.
+// Everything is on by default; only switched-off categories are stored.
+type NotificationPreference struct {
+	Muted map[NotificationCategory]bool `json:"muted,omitempty"`
+}
+
+// Enabled reports whether the user wants notifications of category c.
+func (p NotificationPreference) Enabled(c NotificationCategory) bool {
+	return !p.Muted[c]
+}
+
+// SetNotification switches a notification category on or off for userID.
+func (s *UserStore) SetNotification(userID int64, category NotificationCategory, enabled bool) error {
+	if !validCategory(category) {
+		return fmt.Errorf("unknown notification category %q", category)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := s.userLocked(userID)
+	if enabled {
+		delete(prefs.Notifications.Muted, category)
+	} else {
+		if prefs.Notifications.Muted == nil {
+			prefs.Notifications.Muted = make(map[NotificationCategory]bool)
+		}
+		prefs.Notifications.Muted[category] = true
+	}
+	return s.saveToFile()
+}
+
+func validCategory(c NotificationCategory) bool {
+	for _, known := range NotificationCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// errEncode marks save failures that retrying can't fix.
+var errEncode = errors.New("encode prefs")
+
+// pendingSave records that in-memory changes haven't reached the file yet.
+type pendingSave struct {
+	active bool
+	since  time.Time // first failed save
+	err    error     // latest failure
+}
+
+// SaveRetrier is implemented by stores that keep changes in memory when
+// persisting them fails and retry later, instead of failing the caller.
+type SaveRetrier interface {
+	// PendingSave reports whether changes are waiting to be persisted, since
+	// when, and the latest error.
+	PendingSave() (since time.Time, lastErr error, pending bool)
+	// RetrySave tries to persist pending changes; it returns nil once they are.
+	RetrySave() error
+}
+
+// UserStore retries failed saves.
+var _ SaveRetrier = (*UserStore)(nil)
+
+// PendingSave reports whether a save failed and hasn't succeeded since.
+func (s *UserStore) PendingSave() (since time.Time, lastErr error, pending bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pending.since, s.pending.err, s.pending.active
+}
+
+// RetrySave writes the current state if an earlier save failed.
+func (s *UserStore) RetrySave() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.pending.active {
+		return nil
+	}
+	if err := s.writeLocked(); err != nil {
+		s.pending.err = err
+		return err
+	}
+	s.pending = pendingSave{}
+	return nil
+}
+
+// PendingSave reports the primary's pending save; the secondary's failures are
+// already logged and counted by DualWrite.
+func (d *DualWrite) PendingSave() (since time.Time, lastErr error, pending bool) {
+	if r, ok := d.primary.(SaveRetrier); ok {
+		return r.PendingSave()
+	}
+	return time.Time{}, nil, false
+}
+
+// RetrySave retries both backends' pending saves, reporting the primary's result.
+func (d *DualWrite) RetrySave() error {
+	if r, ok := d.secondary.(SaveRetrier); ok {
+		_ = r.RetrySave()
+	}
+	if r, ok := d.primary.(SaveRetrier); ok {
+		return r.RetrySave()
+	}
+	return nil
+}
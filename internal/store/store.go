@@ -0,0 +1,78 @@
+package store
+
+import "time"
+
+// Store is everything the bot needs from persistence. UserStore, backed by a
+// JSON file, is the built-in implementation; another backend (SQLite, Redis,
+// Postgres) only has to satisfy this interface to be passed to bot.NewHandler.
+type Store interface {
+	// Preferences
+	GetPrefs(userID int64) UserPreferences
+	SetHome(userID int64, siteID string) error
+	SetWork(userID int64, siteID string) error
+	SetPlace(userID int64, alias string, place SavedPlace) error
+	DeletePlace(userID int64, alias string) error
+	SetDirection(userID int64, siteID string, directionCode int) error
+	SetWalkTime(userID int64, siteID string, minutes int) error
+	SetLateAfter(userID int64, lateAfter time.Duration) error
+	SetLayout(userID int64, layout string) error
+	SetWebhookURL(userID int64, webhookURL string) error
+	SetNotification(userID int64, category NotificationCategory, enabled bool) error
+	RecordPush(userID int64, rec PushRecord) error
+	PushHistory(userID int64) []PushRecord
+
+	// Subscriptions
+	AddSubscription(userID int64, sub Subscription) (Subscription, error)
+	RemoveSubscription(userID int64, id int) error
+	AllSubscriptions() map[int64][]Subscription
+	MarkSubscriptionSent(userID int64, id int, day string) error
+
+	// Deviation alerts
+	AddDeviationAlert(userID int64, alert DeviationAlert) (DeviationAlert, error)
+	RemoveDeviationAlert(userID int64, id int) error
+	AllDeviationAlerts() map[int64][]DeviationAlert
+	MarkDeviationSent(userID int64, id int, key string, now time.Time) error
+
+	// Daily digests
+	SetDigest(userID int64, digest *Digest) error
+	AllDigests() map[int64]Digest
+	MarkDigestSent(userID int64, day string) error
+	Limits() Limits
+	SetLimits(limits Limits) error
+
+	// Per-chat state
+	AddNote(chatID int64, note StopNote) error
+	DeleteNote(chatID int64, index int) error
+	Notes(chatID int64) []StopNote
+	NotesShared(chatID int64) bool
+	SetNotesShared(chatID int64, shared bool) error
+
+	// Access control and guests
+	Ban(userID int64) error
+	Unban(userID int64) error
+	IsBanned(userID int64) bool
+	Banned() []int64
+	CreateGuestToken(guest GuestToken) (string, error)
+	RedeemGuestToken(id string, guestID int64) (GuestToken, error)
+
+	// Inline button state
+	SetMessageContext(chatID int64, messageID int, query string, ttl time.Duration) error
+	MessageContext(chatID int64, messageID int) (string, bool)
+
+	// Update bookkeeping
+	MarkCallback(id string, ttl time.Duration) (bool, error)
+	LastUpdateID() int
+	SetLastUpdateID(id int) error
+
+	Snapshotter
+}
+
+// Snapshotter exports and imports a store's full state, e.g. for Backups or
+// for migrating between backends.
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// UserStore must keep satisfying Store.
+var _ Store = (*UserStore)(nil)
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"fmt"
+)
+
+// Subscription pushes a departure board for a stop once per day within a time window.
+type Subscription struct {
+	ID       int    `json:"id"`
+	ChatID   int64  `json:"chatId"`
+	SiteID   int    `json:"siteId"`
+	SiteName string `json:"siteName"`
+	Start    int    `json:"start"` // minutes after midnight, Stockholm time
+	End      int    `json:"end"`
+	Days     string `json:"days"`     // "daily", "weekdays" or "weekends"
+	LastSent string `json:"lastSent"` // day key of the last push, "" if never
+}
+
+// AddSubscription stores a subscription for userID and returns it with its ID assigned.
+// It fails with ErrUserLimit or ErrGlobalLimit when the store's Limits are reached.
+func (s *UserStore) AddSubscription(userID int64, sub Subscription) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSubscriptionLimitsLocked(userID); err != nil {
+		return Subscription{}, err
+	}
+	prefs := s.userLocked(userID)
+
+	sub.ID = 1
+	for _, existing := range prefs.Subscriptions {
+		if existing.ID >= sub.ID {
+			sub.ID = existing.ID + 1
+		}
+	}
+	prefs.Subscriptions = append(prefs.Subscriptions, sub)
+
+	return sub, s.saveToFile()
+}
+
+// RemoveSubscription deletes the subscription with the given ID.
+func (s *UserStore) RemoveSubscription(userID int64, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return fmt.Errorf("subscription %d not found", id)
+	}
+	for i, sub := range prefs.Subscriptions {
+		if sub.ID == id {
+			prefs.Subscriptions = append(prefs.Subscriptions[:i], prefs.Subscriptions[i+1:]...)
+			return s.saveToFile()
+		}
+	}
+	return fmt.Errorf("subscription %d not found", id)
+}
+
+// AllSubscriptions returns a snapshot of every user's subscriptions, keyed by userID.
+func (s *UserStore) AllSubscriptions() map[int64][]Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[int64][]Subscription)
+	for userID, prefs := range s.prefs {
+		if len(prefs.Subscriptions) > 0 {
+			all[userID] = append([]Subscription(nil), prefs.Subscriptions...)
+		}
+	}
+	return all
+}
+
+// MarkSubscriptionSent records that a subscription was pushed on day.
+func (s *UserStore) MarkSubscriptionSent(userID int64, id int, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return fmt.Errorf("subscription %d not found", id)
+	}
+	for i := range prefs.Subscriptions {
+		if prefs.Subscriptions[i].ID == id {
+			prefs.Subscriptions[i].LastSent = day
+			return s.saveToFile()
+		}
+	}
+	return fmt.Errorf("subscription %d not found", id)
+}
+
+// userLocked returns the preferences for userID, creating them if needed.
+// The caller must hold s.mu for writing.
+func (s *UserStore) userLocked(userID int64) *UserPreferences {
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		prefs = &UserPreferences{}
+		s.prefs[userID] = prefs
+	}
+	return prefs
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// LastUpdateID returns the ID of the last Telegram update recorded with
+// SetLastUpdateID, or 0 if none has been.
+func (s *UserStore) LastUpdateID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastUpdateID
+}
+
+// SetLastUpdateID records that the update with this ID has been processed.
+// IDs only move forward; an older ID is ignored.
+//
+// Every update moves the ID, so rather than rewrite the whole store it is
+// written to a small file of its own next to it (see offsetFile); the store
+// document picks it up with the next regular save.
+func (s *UserStore) SetLastUpdateID(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id <= s.lastUpdateID {
+		return nil
+	}
+	s.lastUpdateID = id
+	if s.file == "" {
+		return nil
+	}
+	if err := writeFileAtomic(s.offsetFile(), []byte(strconv.Itoa(id)+"\n"), 0644); err != nil {
+		return fmt.Errorf("write update offset: %w", err)
+	}
+	return nil
+}
+
+// offsetFile is where SetLastUpdateID keeps the update ID: "<file>.offset".
+func (s *UserStore) offsetFile() string {
+	return s.file + ".offset"
+}
+
+// loadOffsetLocked reads the offset file, keeping whichever of it and the
+// store document's ID is further on. A missing file is fine.
+// The caller must hold s.mu for writing (or be the constructor).
+func (s *UserStore) loadOffsetLocked() error {
+	data, err := os.ReadFile(s.offsetFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read update offset: %w", err)
+	}
+	id, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return fmt.Errorf("parse update offset: %w", err)
+	}
+	s.lastUpdateID = max(s.lastUpdateID, id)
+	return nil
+}
@@ -2,23 +2,91 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // UserPreferences holds a user's site ID choices.
 type UserPreferences struct {
 	HomeSiteID string `json:"homeSiteID"`
 	WorkSiteID string `json:"workSiteID"`
+
+	Subscriptions   []Subscription   `json:"subscriptions,omitempty"`
+	DeviationAlerts []DeviationAlert `json:"deviationAlerts,omitempty"`
+	Digest          *Digest          `json:"digest,omitempty"`     // nil when off
+	WebhookURL      string           `json:"webhookURL,omitempty"` // personal event webhook, "" if unset
+
+	Places map[string]SavedPlace `json:"places,omitempty"` // alias ("gym") -> stop
+
+	// LateAfterSeconds overrides how late a departure must be to be shown as late; 0 uses the bot default.
+	LateAfterSeconds int `json:"lateAfterSeconds,omitempty"`
+
+	// Layout is how departure boards are laid out for the user: LayoutList
+	// (also "") or LayoutLines.
+	Layout string `json:"layout,omitempty"`
+
+	// Directions maps a site ID to the SL direction code (1 or 2) the user cares
+	// about there; boards for that site hide the other direction.
+	Directions map[string]int `json:"directions,omitempty"`
+
+	// WalkMinutes maps a site ID to how long the user needs to walk there;
+	// departure alarms go off that much earlier.
+	WalkMinutes map[string]int `json:"walkMinutes,omitempty"`
+
+	// History is the user's recent briefings and alerts, see RecordPush.
+	History []PushRecord `json:"history,omitempty"`
+
+	Notifications NotificationPreference `json:"notifications"`
+}
+
+// SavedPlace is a named stop a user can refer to by alias.
+type SavedPlace struct {
+	SiteID   int       `json:"siteId"`
+	SiteName string    `json:"siteName"`
+	Expires  time.Time `json:"expires,omitempty"` // set for places borrowed via a guest link
 }
 
 // UserStore manages user preferences in memory and optionally persists to a JSON file.
+// Alongside per-user preferences it keeps per-chat state (e.g. shared stop notes).
 type UserStore struct {
 	mu    sync.RWMutex
 	prefs map[int64]*UserPreferences // map of userID -> preferences
-	file  string                      // path to persistence file (optional)
+	chats map[int64]*ChatState       // map of chatID -> chat state
+	// processed callback query IDs -> expiry, see MarkCallback
+	callbacks map[string]time.Time
+	file      string // path to persistence file (optional)
+	limits    Limits // caps on subscriptions and alerts, see SetLimits
+	banned    map[int64]bool
+	guests    map[string]*GuestToken     // guest link token -> grant
+	messages  map[string]*MessageContext // "chatID:messageID" -> query, see SetMessageContext
+
+	fileLocking bool        // flock the file while saving, see SetFileLocking
+	base        []byte      // file contents as last read or written, see mergeDocs
+	pending     pendingSave // set while the file is behind memory, see RetrySave
+
+	lastUpdateID int // last processed Telegram update, also in its own file; see SetLastUpdateID
+}
+
+// storeFile is the on-disk layout of the persistence file.
+// Older files are a flat map of userID -> preferences; loadFromFile still accepts those.
+type storeFile struct {
+	Users map[string]*UserPreferences `json:"users"`
+	Chats map[string]*ChatState       `json:"chats,omitempty"`
+
+	Callbacks map[string]time.Time `json:"callbacks,omitempty"`
+	Limits    *Limits              `json:"limits,omitempty"`
+	Banned    []int64              `json:"banned,omitempty"`
+
+	Guests   map[string]*GuestToken     `json:"guests,omitempty"`
+	Messages map[string]*MessageContext `json:"messages,omitempty"`
+
+	LastUpdateID int `json:"lastUpdateId,omitempty"`
 }
 
 // NewUserStore creates a new in-memory user store.
@@ -26,7 +94,14 @@ type UserStore struct {
 func NewUserStore(filePath string) *UserStore {
 	store := &UserStore{
 		prefs: make(map[int64]*UserPreferences),
+		chats: make(map[int64]*ChatState),
 		file:  filePath,
+
+		callbacks: make(map[string]time.Time),
+		limits:    DefaultLimits,
+		banned:    make(map[int64]bool),
+		guests:    make(map[string]*GuestToken),
+		messages:  make(map[string]*MessageContext),
 	}
 
 	// Load from file if it exists.
@@ -54,7 +129,53 @@ func (s *UserStore) GetPrefs(userID int64) UserPreferences {
 	defer s.mu.RUnlock()
 
 	if prefs, exists := s.prefs[userID]; exists {
-		return *prefs
+		copied := *prefs
+		// Copy the map so callers can't race with SetPlace/DeletePlace.
+		// Expired guest places are left out.
+		if prefs.Places != nil {
+			now := time.Now()
+			copied.Places = make(map[string]SavedPlace, len(prefs.Places))
+			for alias, place := range prefs.Places {
+				if !place.Expires.IsZero() && now.After(place.Expires) {
+					continue
+				}
+				copied.Places[alias] = place
+			}
+		}
+		copied.History = append([]PushRecord(nil), prefs.History...)
+		// Subscriptions are marked sent and removed in place.
+		copied.Subscriptions = append([]Subscription(nil), prefs.Subscriptions...)
+		if prefs.Notifications.Muted != nil {
+			copied.Notifications.Muted = make(map[NotificationCategory]bool, len(prefs.Notifications.Muted))
+			for c, muted := range prefs.Notifications.Muted {
+				copied.Notifications.Muted[c] = muted
+			}
+		}
+		if prefs.WalkMinutes != nil {
+			copied.WalkMinutes = make(map[string]int, len(prefs.WalkMinutes))
+			for siteID, mins := range prefs.WalkMinutes {
+				copied.WalkMinutes[siteID] = mins
+			}
+		}
+		if prefs.Directions != nil {
+			copied.Directions = make(map[string]int, len(prefs.Directions))
+			for siteID, code := range prefs.Directions {
+				copied.Directions[siteID] = code
+			}
+		}
+		// Alerts and the digest are updated in place when pushes go out.
+		if prefs.DeviationAlerts != nil {
+			copied.DeviationAlerts = make([]DeviationAlert, len(prefs.DeviationAlerts))
+			for i, alert := range prefs.DeviationAlerts {
+				alert.Sent = maps.Clone(alert.Sent)
+				copied.DeviationAlerts[i] = alert
+			}
+		}
+		if prefs.Digest != nil {
+			digest := *prefs.Digest
+			copied.Digest = &digest
+		}
+		return copied
 	}
 	return UserPreferences{}
 }
@@ -85,6 +206,106 @@ func (s *UserStore) SetWork(userID int64, siteID string) error {
 	return s.saveToFile()
 }
 
+// SetPlace saves (or overwrites) a place alias for a user.
+func (s *UserStore) SetPlace(userID int64, alias string, place SavedPlace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := s.userLocked(userID)
+	if prefs.Places == nil {
+		prefs.Places = make(map[string]SavedPlace)
+	}
+	prefs.Places[alias] = place
+
+	return s.saveToFile()
+}
+
+// DeletePlace removes a place alias.
+func (s *UserStore) DeletePlace(userID int64, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, exists := s.prefs[userID]
+	if !exists {
+		return fmt.Errorf("place %q not found", alias)
+	}
+	if _, ok := prefs.Places[alias]; !ok {
+		return fmt.Errorf("place %q not found", alias)
+	}
+	delete(prefs.Places, alias)
+
+	return s.saveToFile()
+}
+
+// SetDirection saves the direction code to show at siteID; 0 shows both again.
+func (s *UserStore) SetDirection(userID int64, siteID string, directionCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := s.userLocked(userID)
+	if directionCode == 0 {
+		delete(prefs.Directions, siteID)
+	} else {
+		if prefs.Directions == nil {
+			prefs.Directions = make(map[string]int)
+		}
+		prefs.Directions[siteID] = directionCode
+	}
+	return s.saveToFile()
+}
+
+// SetWalkTime saves how many minutes userID needs to walk to siteID; 0 clears it.
+func (s *UserStore) SetWalkTime(userID int64, siteID string, minutes int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs := s.userLocked(userID)
+	if minutes == 0 {
+		delete(prefs.WalkMinutes, siteID)
+	} else {
+		if prefs.WalkMinutes == nil {
+			prefs.WalkMinutes = make(map[string]int)
+		}
+		prefs.WalkMinutes[siteID] = minutes
+	}
+	return s.saveToFile()
+}
+
+// SetLateAfter sets how late a departure must be before it is labelled late for
+// userID; 0 restores the bot-wide default.
+func (s *UserStore) SetLateAfter(userID int64, lateAfter time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userLocked(userID).LateAfterSeconds = int(lateAfter / time.Second)
+	return s.saveToFile()
+}
+
+// Board layouts a user can choose with SetLayout.
+const (
+	LayoutList  = "list"  // one row per departure, the default
+	LayoutLines = "lines" // one row per line with its next few times
+)
+
+// SetLayout sets how departure boards are laid out for a user.
+func (s *UserStore) SetLayout(userID int64, layout string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userLocked(userID).Layout = layout
+	return s.saveToFile()
+}
+
+// SetWebhookURL sets (or, with "", clears) a user's personal webhook URL.
+func (s *UserStore) SetWebhookURL(userID int64, webhookURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userLocked(userID).WebhookURL = webhookURL
+
+	return s.saveToFile()
+}
+
 // loadFromFile loads preferences from a JSON file.
 func (s *UserStore) loadFromFile() error {
 	if s.file == "" {
@@ -99,29 +320,108 @@ func (s *UserStore) loadFromFile() error {
 		return fmt.Errorf("read prefs file: %w", err)
 	}
 
-	var prefs map[string]*UserPreferences
-	if err := json.Unmarshal(data, &prefs); err != nil {
+	if err := s.decodeLocked(data); err != nil {
+		return err
+	}
+	s.base = data
+	return s.loadOffsetLocked()
+}
+
+// decodeLocked parses a persistence document into the in-memory maps.
+// The caller must hold s.mu for writing (or be the constructor).
+func (s *UserStore) decodeLocked(data []byte) error {
+	// Sniff the top-level keys: the current layout has "users", legacy files only have user IDs.
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
 		return fmt.Errorf("unmarshal prefs: %w", err)
 	}
 
-	// Convert string keys to int64 userIDs.
-	for keyStr, userPrefs := range prefs {
-		var userID int64
-		if _, err := fmt.Sscanf(keyStr, "%d", &userID); err != nil {
+	// Limits missing from the file (older files, newer limits) keep their defaults.
+	defaults := DefaultLimits
+	doc := storeFile{Limits: &defaults}
+	if _, ok := top["users"]; ok {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("unmarshal prefs: %w", err)
+		}
+	} else {
+		if err := json.Unmarshal(data, &doc.Users); err != nil {
+			return fmt.Errorf("unmarshal legacy prefs: %w", err)
+		}
+	}
+
+	// Convert string keys to int64 IDs.
+	for keyStr, userPrefs := range doc.Users {
+		userID, err := parseID(keyStr)
+		if err != nil {
 			continue // Skip invalid keys.
 		}
 		s.prefs[userID] = userPrefs
 	}
+	for keyStr, chat := range doc.Chats {
+		chatID, err := parseID(keyStr)
+		if err != nil {
+			continue
+		}
+		s.chats[chatID] = chat
+	}
+	for id, expires := range doc.Callbacks {
+		s.callbacks[id] = expires
+	}
+	if doc.Limits != nil {
+		s.limits = *doc.Limits
+	}
+	for _, id := range doc.Banned {
+		s.banned[id] = true
+	}
+	for id, t := range doc.Guests {
+		s.guests[id] = t
+	}
+	for key, mc := range doc.Messages {
+		s.messages[key] = mc
+	}
+	s.lastUpdateID = doc.LastUpdateID
 
 	return nil
 }
 
+// parseID converts a JSON object key back into a Telegram user or chat ID.
+func parseID(key string) (int64, error) {
+	var id int64
+	if _, err := fmt.Sscanf(key, "%d", &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 // saveToFile persists preferences to a JSON file.
+// If the write fails (disk full, permissions) the change stays in memory and
+// the save is left pending for RetrySave; the caller isn't failed for it.
+// The caller must hold s.mu for writing.
 func (s *UserStore) saveToFile() error {
 	if s.file == "" {
 		return nil
 	}
 
+	err := s.writeLocked()
+	if err == nil {
+		s.pending = pendingSave{}
+		return nil
+	}
+	if errors.Is(err, errEncode) {
+		return err // retrying won't help
+	}
+	if !s.pending.active {
+		log.Printf("saveToFile: %v; keeping changes in memory and retrying", err)
+		s.pending = pendingSave{active: true, since: time.Now()}
+	}
+	s.pending.err = err
+	return nil
+}
+
+// writeLocked encodes the store and writes it to s.file. With file locking on,
+// the file is re-read under the lock and changes other processes made since
+// this one last read or wrote it are merged in first, see mergeLocked.
+func (s *UserStore) writeLocked() error {
 	// Ensure directory exists before writing file.
 	dir := filepath.Dir(s.file)
 	if dir != "." {
@@ -130,20 +430,111 @@ func (s *UserStore) saveToFile() error {
 		}
 	}
 
-	// Convert int64 keys to string for JSON.
-	prefs := make(map[string]*UserPreferences)
-	for userID, userPrefs := range s.prefs {
-		prefs[fmt.Sprintf("%d", userID)] = userPrefs
+	if s.fileLocking {
+		unlock, err := lockFile(s.file)
+		if err != nil {
+			return fmt.Errorf("lock prefs file: %w", err)
+		}
+		defer unlock()
+
+		if err := s.mergeLocked(); err != nil {
+			return err
+		}
 	}
 
-	data, err := json.MarshalIndent(prefs, "", "  ")
+	data, err := s.encodeLocked()
 	if err != nil {
-		return fmt.Errorf("marshal prefs: %w", err)
+		return fmt.Errorf("%w: %w", errEncode, err)
 	}
 
-	if err := os.WriteFile(s.file, data, 0644); err != nil {
+	// Write a temp file and rename it into place, so a crash mid-write can't corrupt the prefs.
+	if err := writeFileAtomic(s.file, data, 0644); err != nil {
 		return fmt.Errorf("write prefs file: %w", err)
 	}
+	s.base = data
 
 	return nil
 }
+
+// encodeLocked renders the in-memory state as a persistence document.
+// The caller must hold s.mu (read or write).
+func (s *UserStore) encodeLocked() ([]byte, error) {
+	// Convert int64 keys to string for JSON.
+	doc := storeFile{
+		Users: make(map[string]*UserPreferences),
+		Chats: make(map[string]*ChatState),
+	}
+	for userID, userPrefs := range s.prefs {
+		doc.Users[fmt.Sprintf("%d", userID)] = userPrefs
+	}
+	for chatID, chat := range s.chats {
+		doc.Chats[fmt.Sprintf("%d", chatID)] = chat
+	}
+	doc.Callbacks = s.callbacks
+	limits := s.limits
+	doc.Limits = &limits
+	doc.Banned = s.bannedLocked()
+	doc.Guests = s.guests
+	doc.Messages = s.messages
+	doc.LastUpdateID = s.lastUpdateID
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal prefs: %w", err)
+	}
+	return data, nil
+}
+
+// Snapshot returns the store's full state in its persistence format.
+func (s *UserStore) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.encodeLocked()
+}
+
+// Restore replaces the store's state with a snapshot and persists it.
+// The snapshot is validated before anything is replaced.
+func (s *UserStore) Restore(data []byte) error {
+	fresh, err := decodeState(data)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.adoptLocked(fresh)
+	return s.saveToFile()
+}
+
+// decodeState parses a persistence document into a store that isn't backed by a file.
+func decodeState(data []byte) (*UserStore, error) {
+	fresh := &UserStore{
+		prefs:     make(map[int64]*UserPreferences),
+		chats:     make(map[int64]*ChatState),
+		callbacks: make(map[string]time.Time),
+		limits:    DefaultLimits,
+		banned:    make(map[int64]bool),
+		guests:    make(map[string]*GuestToken),
+		messages:  make(map[string]*MessageContext),
+	}
+	if err := fresh.decodeLocked(data); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// adoptLocked replaces s's state with fresh's. The caller must hold s.mu for writing.
+func (s *UserStore) adoptLocked(fresh *UserStore) {
+	s.prefs = fresh.prefs
+	s.chats = fresh.chats
+	s.callbacks = fresh.callbacks
+	s.limits = fresh.limits
+	s.banned = fresh.banned
+	s.guests = fresh.guests
+	s.messages = fresh.messages
+	// The update ID only moves forward: a merged or restored document may be
+	// behind SetLastUpdateID, which doesn't save the document.
+	s.lastUpdateID = max(s.lastUpdateID, fresh.lastUpdateID)
+}
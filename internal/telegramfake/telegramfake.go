@@ -0,0 +1,249 @@
+// Package telegramfake is an in-process stand-in for the Telegram Bot API, for
+// driving the bot end to end offline. The real tgbotapi client is pointed at
+// it, updates are queued with PushUpdate and served from getUpdates exactly as
+// Telegram would, and every call the bot makes is recorded for inspection:
+//
+//	fake := telegramfake.NewServer()
+//	defer fake.Close()
+//	api, _ := fake.API()
+//	fake.PushUpdate(telegramfake.TextUpdate(42, "to work"))
+//	// ... run the bot's update loop against api ...
+//	call, err := fake.Next("sendMessage", 2*time.Second)
+//	// call.Params.Get("text") holds the board
+//
+// It implements just enough of the API for the bot: send* and edit* methods
+// answer with a message, getMe with a bot user, getUpdates with the queue,
+// and everything else with true.
+package telegramfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxPollWait caps how long getUpdates holds a request open waiting for an
+// update, whatever timeout the client asks for, so shutdown stays quick.
+const maxPollWait = time.Second
+
+// Call is one Bot API request made to the fake.
+type Call struct {
+	Method    string
+	Params    url.Values // form parameters; reply_markup and the like are JSON strings
+	MessageID int        // ID of the message sent or edited, 0 for other methods
+	At        time.Time
+}
+
+// Server is a fake Bot API server. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	calls      []Call
+	cursor     int // calls before this index have been consumed by Next
+	updates    []tgbotapi.Update
+	nextUpdate int
+	nextMsgID  int
+	changed    chan struct{} // closed and replaced whenever calls or updates change
+}
+
+// NewServer starts a fake Bot API server. Close it when done.
+func NewServer() *Server {
+	s := &Server{nextUpdate: 1, nextMsgID: 1, changed: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// API returns a tgbotapi client talking to the fake.
+func (s *Server) API() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithClient("fake-token", s.URL+"/bot%s/%s", s.Client())
+}
+
+// PushUpdate queues an update for getUpdates, assigning its UpdateID.
+func (s *Server) PushUpdate(u tgbotapi.Update) tgbotapi.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u.UpdateID = s.nextUpdate
+	s.nextUpdate++
+	s.updates = append(s.updates, u)
+	s.notifyLocked()
+	return u
+}
+
+// Calls returns every call recorded so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// CallCount returns how many calls have been made, getUpdates included.
+func (s *Server) CallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.calls)
+}
+
+// Next waits up to timeout for the next call of method not yet returned by
+// Next, and returns it. Calls of other methods it passes over are consumed too,
+// so a scenario reads as a sequence of expected calls.
+func (s *Server) Next(method string, timeout time.Duration) (Call, error) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		for s.cursor < len(s.calls) {
+			call := s.calls[s.cursor]
+			s.cursor++
+			if call.Method == method {
+				s.mu.Unlock()
+				return call, nil
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return Call{}, fmt.Errorf("no %s call within %s", method, timeout)
+		}
+	}
+}
+
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := path.Base(r.URL.Path)
+	params := r.PostForm
+
+	call := Call{Method: method, Params: params, At: time.Now()}
+	var result any
+	switch {
+	case method == "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, FirstName: "fake", UserName: "fake_bot"}
+	case method == "getUpdates":
+		result = s.pollUpdates(r, params)
+	case strings.HasPrefix(method, "send"), strings.HasPrefix(method, "edit"):
+		msg := s.message(method, params)
+		call.MessageID = msg.MessageID
+		result = msg
+	default:
+		result = true
+	}
+
+	if method != "getUpdates" {
+		s.mu.Lock()
+		s.calls = append(s.calls, call)
+		s.notifyLocked()
+		s.mu.Unlock()
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// pollUpdates answers getUpdates with queued updates from offset on, waiting
+// for one to arrive if none are queued.
+func (s *Server) pollUpdates(r *http.Request, params url.Values) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	wait := maxPollWait
+	if secs, err := strconv.Atoi(params.Get("timeout")); err == nil && time.Duration(secs)*time.Second < wait {
+		wait = time.Duration(secs) * time.Second
+	}
+	deadline := time.After(wait)
+
+	for {
+		s.mu.Lock()
+		var pending []tgbotapi.Update
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				pending = append(pending, u)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(pending) > 0 {
+			return pending
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return []tgbotapi.Update{}
+		case <-r.Context().Done():
+			return []tgbotapi.Update{}
+		}
+	}
+}
+
+// message builds the Message a send or edit call returns. Sends get a new
+// message ID; edits echo the one they were given.
+func (s *Server) message(method string, params url.Values) tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	msgID, err := strconv.Atoi(params.Get("message_id"))
+	if err != nil || strings.HasPrefix(method, "send") {
+		s.mu.Lock()
+		msgID = s.nextMsgID
+		s.nextMsgID++
+		s.mu.Unlock()
+	}
+	return tgbotapi.Message{
+		MessageID: msgID,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Text:      params.Get("text"),
+	}
+}
+
+// incoming numbers messages and callbacks built by TextUpdate and CallbackUpdate.
+var incoming atomic.Int64
+
+// TextUpdate builds a private-chat text message update from userID.
+func TextUpdate(userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: int(incoming.Add(1)),
+		From:      &tgbotapi.User{ID: userID, FirstName: "test"},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}}
+}
+
+// CallbackUpdate builds a button press by userID on message messageID in the
+// user's private chat, carrying data.
+func CallbackUpdate(userID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   "cb" + strconv.FormatInt(incoming.Add(1), 10),
+		From: &tgbotapi.User{ID: userID, FirstName: "test"},
+		Message: &tgbotapi.Message{
+			MessageID: messageID,
+			Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		},
+		Data: data,
+	}}
+}
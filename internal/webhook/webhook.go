@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Event types. Receivers should ignore types they don't know.
+const (
+	EventBriefingSent = "briefing.sent" // a scheduled departure board was pushed
+	EventAlertFired   = "alert.fired"   // a departure alarm or deviation alert was pushed; data.kind says which
+)
+
+// Event is the JSON body POSTed to webhook URLs.
+type Event struct {
+	Type   string         `json:"type"`
+	Time   time.Time      `json:"time"`
+	UserID int64          `json:"userId"`
+	ChatID int64          `json:"chatId,omitempty"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+// maxInFlight bounds concurrent deliveries so a slow receiver can't pile up goroutines.
+const maxInFlight = 16
+
+// Dispatcher delivers events asynchronously to operator-wide URLs and an optional
+// per-user URL. Deliveries are retried a few times with backoff and never block
+// the caller. When a secret is configured, each body is signed with HMAC-SHA256 in
+// the X-Slbot-Signature header so receivers can verify the sender: operator URLs
+// with the secret itself, user URLs with that user's UserSecret.
+//
+// User URLs are untrusted, so they are delivered by a separate client that only
+// connects to public addresses; see publicOnly.
+type Dispatcher struct {
+	client       *http.Client
+	userClient   *http.Client
+	operatorURLs []string
+	secret       []byte
+	sem          chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher. operatorURLs receive every event; secret may be empty.
+func NewDispatcher(client *http.Client, operatorURLs []string, secret string) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicOnly}
+	return &Dispatcher{
+		client: client,
+		userClient: &http.Client{
+			// No proxy: the dialer must see the receiver's address to vet it.
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+			Timeout:   client.Timeout,
+		},
+		operatorURLs: operatorURLs,
+		secret:       []byte(secret),
+		sem:          make(chan struct{}, maxInFlight),
+	}
+}
+
+// UserSecret returns the key that signs deliveries to userID's personal webhook,
+// derived from the operator secret so it needn't be stored. It is "" (and user
+// deliveries are unsigned) when no secret is configured.
+func (d *Dispatcher) UserSecret(userID int64) string {
+	if len(d.secret) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte("user:" + strconv.FormatInt(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// delivery is one target of an event with the client and key to use for it.
+type delivery struct {
+	target string
+	client *http.Client
+	secret []byte
+}
+
+// Send queues ev for the operator URLs and, if non-empty, userURL.
+// Events are dropped (and logged) if too many deliveries are already in flight.
+func (d *Dispatcher) Send(ev Event, userURL string) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("webhook: marshal %s: %v", ev.Type, err)
+		return
+	}
+
+	var targets []delivery
+	for _, target := range d.operatorURLs {
+		targets = append(targets, delivery{target: target, client: d.client, secret: d.secret})
+	}
+	if userURL != "" {
+		targets = append(targets, delivery{target: userURL, client: d.userClient, secret: []byte(d.UserSecret(ev.UserID))})
+	}
+
+	for _, t := range targets {
+		select {
+		case d.sem <- struct{}{}:
+		default:
+			log.Printf("webhook: dropping %s for %s: too many deliveries in flight", ev.Type, t.target)
+			continue
+		}
+		go func(t delivery) {
+			defer func() { <-d.sem }()
+			d.deliver(t, ev.Type, body)
+		}(t)
+	}
+}
+
+// deliver POSTs body to t, retrying on network errors and 5xx responses.
+func (d *Dispatcher) deliver(t delivery, eventType string, body []byte) {
+	backoff := time.Second
+	for attempt := 1; attempt <= 3; attempt++ {
+		err := d.post(t, eventType, body)
+		if err == nil {
+			return
+		}
+		log.Printf("webhook: %s to %s (attempt %d): %v", eventType, t.target, attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post performs a single delivery attempt.
+func (d *Dispatcher) post(t delivery, eventType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Slbot-Event", eventType)
+	if len(t.secret) > 0 {
+		mac := hmac.New(sha256.New, t.secret)
+		mac.Write(body)
+		req.Header.Set("X-Slbot-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 400 {
+		// Client errors won't fix themselves on retry; log once and give up.
+		log.Printf("webhook: %s to %s rejected with status %d", eventType, t.target, resp.StatusCode)
+	}
+	return nil
+}
+
+// ValidateURL checks that a user-supplied webhook URL is an absolute https URL
+// and, if it names an IP address or localhost, that the address is public.
+// Host names are checked again when connecting, since DNS can change.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("webhook URL must start with https://")
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return fmt.Errorf("webhook URL must be a public address")
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublic(ip) {
+		return fmt.Errorf("webhook URL must be a public address")
+	}
+	return nil
+}
+
+// publicOnly is a net.Dialer Control hook that refuses connections to
+// non-public addresses. It runs after DNS resolution, on the address actually
+// dialled, so a host name that resolves (or is rebound) to an internal address
+// is still refused.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook: bad address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return fmt.Errorf("webhook: refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// carrierNAT is the shared address space of RFC 6598, not covered by IsPrivate.
+var carrierNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublic reports whether ip is a globally routable unicast address. Loopback,
+// private, link-local (which includes cloud metadata at 169.254.169.254),
+// carrier-grade NAT, multicast and unspecified addresses are not.
+func isPublic(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() && !carrierNAT.Contains(ip)
+}